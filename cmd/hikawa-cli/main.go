@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jasmaa/hikawa/pkg/gemini"
+	"github.com/jasmaa/hikawa/pkg/gemtext"
+	"github.com/jasmaa/hikawa/pkg/protocol"
+)
+
+func main() {
+	width := flag.Int("width", terminalWidth(), "width to wrap text to, or 0 to not wrap")
+	noColor := flag.Bool("no-color", false, "do not style text with ANSI escape sequences")
+	interactive := flag.Bool("i", false, "browse interactively by following numbered links")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] url\n\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	b := &browser{
+		registry: newRegistry(),
+		options: gemtext.AnsiOptions{
+			Width:   *width,
+			NoColor: *noColor || len(os.Getenv("NO_COLOR")) > 0 || !isTerminal(os.Stdout),
+		},
+		stdin:       bufio.NewReader(os.Stdin),
+		interactive: *interactive,
+	}
+	if err := b.run(flag.Arg(0)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// newRegistry makes a protocol registry that pins gemini certificates in the
+// known hosts file shared with the graphical browser.
+func newRegistry() *protocol.Registry {
+	client := gemini.MakeClient()
+	if configDir, err := os.UserConfigDir(); err == nil {
+		knownHosts, err := gemini.OpenFileKnownHosts(filepath.Join(configDir, "hikawa", "known_hosts"))
+		if err == nil {
+			client.KnownHosts = knownHosts
+		}
+		identities, err := gemini.OpenFileIdentities(filepath.Join(configDir, "hikawa", "identities"))
+		if err == nil {
+			client.Identities = identities
+		}
+	}
+	registry := protocol.NewDefaultRegistry()
+	registry.Register("gemini", &protocol.GeminiFetcher{Client: &client})
+	return registry
+}
+
+// browser shows pages in the terminal.
+type browser struct {
+	registry    *protocol.Registry
+	options     gemtext.AnsiOptions
+	stdin       *bufio.Reader
+	interactive bool
+	history     []string
+}
+
+// run shows a page, then in interactive mode reads commands until quit.
+func (b *browser) run(rawurl string) error {
+	doc, currentUrl, err := b.show(rawurl)
+	if !b.interactive {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	for {
+		fmt.Print("\nLink number, url, b to go back or q to quit: ")
+		command, err := b.stdin.ReadString('\n')
+		command = strings.TrimSpace(command)
+		if err != nil && len(command) == 0 || command == "q" {
+			return nil
+		}
+
+		nextUrl := command
+		if command == "b" {
+			if len(b.history) < 2 {
+				continue
+			}
+			nextUrl = b.history[len(b.history)-2]
+			b.history = b.history[:len(b.history)-2]
+		} else if n, err := strconv.Atoi(command); err == nil {
+			links := gemtext.LinkUrls(doc)
+			if n < 1 || n > len(links) {
+				fmt.Fprintf(os.Stderr, "no link %d\n", n)
+				continue
+			}
+			if nextUrl, err = gemini.NextUrl(currentUrl, links[n-1]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			if isPrompt(doc, n) {
+				nextUrl = b.withInput(nextUrl, "Input")
+			}
+		}
+
+		nextDoc, nextCurrentUrl, err := b.show(nextUrl)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		doc, currentUrl = nextDoc, nextCurrentUrl
+	}
+}
+
+// show fetches and prints a page, asking for input when the page needs it.
+// Gemtext pages are returned so their links can be followed.
+func (b *browser) show(rawurl string) (*gemtext.Document, string, error) {
+	resp, err := b.registry.Fetch(context.Background(), rawurl)
+	var inputErr *protocol.InputError
+	if errors.As(err, &inputErr) && b.interactive {
+		return b.show(b.withInput(inputErr.Url, inputErr.Prompt))
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	b.history = append(b.history, resp.Url)
+
+	switch {
+	case resp.MediaType.String() == "text/gemini":
+		doc, err := gemtext.Parse(resp.Body)
+		if err != nil {
+			return nil, "", err
+		}
+		fmt.Print(gemtext.RenderAnsi(doc, b.options))
+		return doc, resp.Url, nil
+	case resp.MediaType.Type == "text":
+		data, err := io.ReadAll(resp.Body)
+		fmt.Print(gemtext.StripControl(string(data)))
+		return &gemtext.Document{}, resp.Url, err
+	case !b.interactive:
+		_, err := io.Copy(os.Stdout, resp.Body)
+		return &gemtext.Document{}, resp.Url, err
+	}
+	fmt.Printf("Cannot display %s\n", resp.MediaType)
+	return &gemtext.Document{}, resp.Url, nil
+}
+
+// withInput asks for input and adds it to a url as its query.
+func (b *browser) withInput(rawurl string, prompt string) string {
+	fmt.Printf("%s: ", prompt)
+	input, _ := b.stdin.ReadString('\n')
+	base, _, _ := strings.Cut(rawurl, "?")
+	return base + "?" + gemini.EscapeQuery(strings.TrimRight(input, "\r\n"))
+}
+
+// isPrompt checks if link n of a document is a Spartan input prompt.
+func isPrompt(doc *gemtext.Document, n int) bool {
+	for _, line := range doc.Lines {
+		switch line.(type) {
+		case gemtext.Link:
+			n--
+		case gemtext.Prompt:
+			n--
+			if n == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// terminalWidth gets the terminal width from COLUMNS, defaulting to 80.
+func terminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	return 80
+}
+
+// isTerminal checks if a file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
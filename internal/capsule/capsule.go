@@ -0,0 +1,56 @@
+// Package capsule holds helpers for serving files as a capsule, shared by the
+// server and the file url fetcher.
+package capsule
+
+import (
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// SNIFF_LENGTH is how much of a file MediaType needs to sniff its media type.
+const SNIFF_LENGTH = 512
+
+// DirectoryIndex makes a gemtext listing of the entries of the directory at
+// dirPath, which ends in a slash. Links are relative to the directory and
+// entries starting with a dot are left out.
+func DirectoryIndex(dirPath string, entries []fs.DirEntry) string {
+	var index strings.Builder
+	fmt.Fprintf(&index, "# Index of %s\n\n", dirPath)
+	if dirPath != "/" {
+		index.WriteString("=> ../ ../\n")
+	}
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if strings.HasPrefix(entryName, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		link := (&url.URL{Path: entryName}).EscapedPath()
+		if strings.Contains(entryName, ":") {
+			link = "./" + link
+		}
+		fmt.Fprintf(&index, "=> %s %s\n", link, entryName)
+	}
+	return index.String()
+}
+
+// MediaType gets the media type of a file from its extension, or else by
+// sniffing its first bytes. .gmi and .gemini files are text/gemini, and
+// files without a known extension or head are text/plain.
+func MediaType(name string, head []byte) string {
+	ext := strings.ToLower(path.Ext(name))
+	if ext == ".gmi" || ext == ".gemini" {
+		return "text/gemini"
+	}
+	if mediaType := mime.TypeByExtension(ext); len(mediaType) > 0 {
+		return mediaType
+	}
+	return http.DetectContentType(head)
+}
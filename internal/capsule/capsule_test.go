@@ -0,0 +1,16 @@
+package capsule_test
+
+import (
+	"testing"
+
+	"github.com/jasmaa/hikawa/internal/capsule"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMediaType tests detecting media types.
+func TestMediaType(t *testing.T) {
+	assert.Equal(t, "text/gemini", capsule.MediaType("page.GMI", nil))
+	assert.Equal(t, "text/plain; charset=utf-8", capsule.MediaType("README", []byte("hello")))
+	assert.Equal(t, "image/gif", capsule.MediaType("picture", []byte("GIF89a")))
+	assert.Equal(t, "text/plain; charset=utf-8", capsule.MediaType("notes", nil))
+}
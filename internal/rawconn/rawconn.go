@@ -0,0 +1,74 @@
+// Package rawconn sends requests over plain TCP for the line based protocols,
+// e.g. gopher, finger, nex and spartan.
+package rawconn
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// Dialer opens network connections. gemini.Dialer values are Dialers.
+type Dialer interface {
+	DialContext(ctx context.Context, network string, addr string) (net.Conn, error)
+}
+
+// Dial connects to addr and sends a request. The returned body reads the
+// answer with timeout applied to dialing and each read, and closes when ctx
+// is done. If dialer is nil, a net.Dialer is used.
+func Dial(ctx context.Context, dialer Dialer, timeout time.Duration, addr string, request []byte) (*Body, error) {
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	dialCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	conn, err := dialer.DialContext(dialCtx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	body := &Body{conn: conn, timeout: timeout, stop: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-body.stop:
+		}
+	}()
+	if _, err := conn.Write(request); err != nil {
+		body.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return body, nil
+}
+
+// Body reads from a connection with a timeout on each read.
+type Body struct {
+	conn    net.Conn
+	timeout time.Duration
+	stop    chan struct{}
+	once    sync.Once
+}
+
+func (b *Body) Read(p []byte) (int, error) {
+	if b.timeout > 0 {
+		b.conn.SetReadDeadline(time.Now().Add(b.timeout))
+	}
+	return b.conn.Read(p)
+}
+
+func (b *Body) Close() error {
+	var err error
+	b.once.Do(func() {
+		close(b.stop)
+		err = b.conn.Close()
+	})
+	return err
+}
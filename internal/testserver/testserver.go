@@ -0,0 +1,48 @@
+// Package testserver serves raw connections for tests.
+package testserver
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+)
+
+// TCP accepts connections until the test ends, handling each with handle in
+// its own goroutine, and returns the server address. Connections are closed
+// when handle returns.
+func TCP(t testing.TB, handle func(conn net.Conn)) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// TLS is like TCP but serves TLS with cert. Client certificates are requested
+// but not required.
+func TLS(t testing.TB, cert tls.Certificate, handle func(conn *tls.Conn)) string {
+	t.Helper()
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequestClientCert,
+	}
+	return TCP(t, func(conn net.Conn) {
+		tlsConn := tls.Server(conn, config)
+		defer tlsConn.Close()
+		handle(tlsConn)
+	})
+}
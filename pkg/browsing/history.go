@@ -1,6 +1,10 @@
 package browsing
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/jasmaa/hikawa/pkg/gemini"
+)
 
 // History maintains a double-linked list of pages visited.
 type History struct {
@@ -57,7 +61,11 @@ func (h *History) GoForward() error {
 
 // Push pushes and moves the current page to the provided url.
 // This overwrites any history in front of the current page.
+// The url is normalized with gemini.Normalize if it is valid.
 func (h *History) Push(url string) {
+	if normalized, err := gemini.Normalize(url); err == nil {
+		url = normalized
+	}
 	// TODO: clean up list in front (might not be necessary)
 	newNode := &historyNode{
 		Url:      url,
@@ -19,7 +19,7 @@ func TestEmpty(t *testing.T) {
 // TestPushOnce tests pushing one page.
 func TestPush(t *testing.T) {
 	h := browsing.NewHistory()
-	nextUrl := "gemini://example.com"
+	nextUrl := "gemini://example.com/"
 	h.Push(nextUrl)
 	currentUrl, err := h.GetCurrentUrl()
 	if assert.Nil(t, err) {
@@ -77,3 +77,13 @@ func TestPushTwiceAndBackOnceAndPushOnce(t *testing.T) {
 	assert.True(t, h.CanGoBack())
 	assert.False(t, h.CanGoForward())
 }
+
+// TestPushNormalizes tests normalizing pushed urls.
+func TestPushNormalizes(t *testing.T) {
+	h := browsing.NewHistory()
+	h.Push("GEMINI://Example.com:1965/a/../b#top")
+	currentUrl, err := h.GetCurrentUrl()
+	if assert.Nil(t, err) {
+		assert.Equal(t, "gemini://example.com/b", currentUrl)
+	}
+}
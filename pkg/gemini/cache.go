@@ -0,0 +1,289 @@
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheFileExt is the extension of cache entry files.
+const cacheFileExt = ".cache"
+
+// CacheEntry is a success response stored in a ResponseCache.
+type CacheEntry struct {
+	Url    string
+	Header ResponseHeader
+	Body   []byte
+	// Stored is when the response was received.
+	Stored time.Time
+}
+
+// Response makes a Response that reads the cached body.
+func (e CacheEntry) Response() *Response {
+	return &Response{
+		Header: e.Header,
+		Body:   &body{Reader: bytes.NewReader(e.Body)},
+	}
+}
+
+// ResponseCache holds complete success responses keyed by normalized url.
+// Once the bodies add up to more than the size limit, the least recently
+// used entries are evicted. Entries expire after the TTL.
+type ResponseCache struct {
+	maxBytes int64
+	ttl      time.Duration
+	// dir is where entries are persisted, if set
+	dir string
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// NewResponseCache creates an empty in-memory ResponseCache holding up to
+// maxBytes of bodies for ttl each. A maxBytes or ttl of 0 or less is unlimited.
+func NewResponseCache(maxBytes int64, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// OpenResponseCache creates a ResponseCache that also stores entries as files
+// in dir, and loads the entries already there. A missing dir is treated as empty.
+func OpenResponseCache(dir string, maxBytes int64, ttl time.Duration) (*ResponseCache, error) {
+	c := NewResponseCache(maxBytes, ttl)
+	c.dir = dir
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var loaded []CacheEntry
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), cacheFileExt) {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		entry, err := readCacheFile(path)
+		if err != nil || c.expired(entry) {
+			os.Remove(path)
+			continue
+		}
+		loaded = append(loaded, entry)
+	}
+	sort.Slice(loaded, func(i, j int) bool {
+		return loaded[i].Stored.Before(loaded[j].Stored)
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entry := range loaded {
+		c.insert(entry)
+	}
+	return c, nil
+}
+
+// Get gets the unexpired entry for rawurl.
+func (c *ResponseCache) Get(rawurl string) (CacheEntry, bool) {
+	key := cacheKey(rawurl)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return CacheEntry{}, false
+	}
+	entry := elem.Value.(CacheEntry)
+	if c.expired(entry) {
+		c.remove(elem)
+		return CacheEntry{}, false
+	}
+	c.order.MoveToFront(elem)
+	return entry, true
+}
+
+// Put stores an entry, replacing any entry for the same url. Entries larger
+// than the size limit are not stored.
+func (c *ResponseCache) Put(entry CacheEntry) error {
+	if c.maxBytes > 0 && int64(len(entry.Body)) > c.maxBytes {
+		return nil
+	}
+	entry.Url = cacheKey(entry.Url)
+	if entry.Stored.IsZero() {
+		entry.Stored = time.Now()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[entry.Url]; ok {
+		c.remove(elem)
+	}
+	if len(c.dir) > 0 {
+		if err := c.writeCacheFile(entry); err != nil {
+			return err
+		}
+	}
+	c.insert(entry)
+	return nil
+}
+
+// Remove removes the entry for rawurl.
+func (c *ResponseCache) Remove(rawurl string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[cacheKey(rawurl)]; ok {
+		c.remove(elem)
+	}
+}
+
+// Clear removes every entry.
+func (c *ResponseCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.order.Len() > 0 {
+		c.remove(c.order.Back())
+	}
+}
+
+// Size gets the total size of the cached bodies in bytes.
+func (c *ResponseCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// insert adds an entry and evicts entries over the size limit. Caller must hold the lock.
+func (c *ResponseCache) insert(entry CacheEntry) {
+	c.entries[entry.Url] = c.order.PushFront(entry)
+	c.size += int64(len(entry.Body))
+	for c.maxBytes > 0 && c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove removes an entry and its file. Caller must hold the lock.
+func (c *ResponseCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(CacheEntry)
+	delete(c.entries, entry.Url)
+	c.size -= int64(len(entry.Body))
+	if len(c.dir) > 0 {
+		os.Remove(c.cacheFilePath(entry.Url))
+	}
+}
+
+// expired checks if an entry is past the TTL.
+func (c *ResponseCache) expired(entry CacheEntry) bool {
+	return c.ttl > 0 && time.Since(entry.Stored) > c.ttl
+}
+
+// cacheFilePath gets the file an entry for a normalized url is stored in.
+func (c *ResponseCache) cacheFilePath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+cacheFileExt)
+}
+
+// writeCacheFile writes an entry to disk. The file holds the url, the time
+// stored in Unix nanoseconds and then the response as sent by the server.
+func (c *ResponseCache) writeCacheFile(entry CacheEntry) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s\n%d\n%d %s\r\n", entry.Url, entry.Stored.UnixNano(), int(entry.Header.Status), entry.Header.Meta)
+	b.Write(entry.Body)
+
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return err
+	}
+	path := c.cacheFilePath(entry.Url)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readCacheFile reads an entry written by writeCacheFile.
+func readCacheFile(path string) (CacheEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	rawurl, err := reader.ReadString('\n')
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	rawStored, err := reader.ReadString('\n')
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	stored, err := strconv.ParseInt(strings.TrimSuffix(rawStored, "\n"), 10, 64)
+	if err != nil {
+		return CacheEntry{}, fmt.Errorf("malformed cache entry time: %w", err)
+	}
+	resp, err := ReadResponse(reader)
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	data, err := resp.ReadAll(0)
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	return CacheEntry{
+		Url:    strings.TrimSuffix(rawurl, "\n"),
+		Header: resp.Header,
+		Body:   data,
+		Stored: time.Unix(0, stored),
+	}, nil
+}
+
+// cacheKey gets the key for rawurl.
+func cacheKey(rawurl string) string {
+	if normalized, err := Normalize(rawurl); err == nil {
+		return normalized
+	}
+	return rawurl
+}
+
+// cachingBody stores a body in a ResponseCache once it has been read to the end.
+type cachingBody struct {
+	io.ReadCloser
+	cache *ResponseCache
+	entry CacheEntry
+	buf   bytes.Buffer
+	// skip is set once the body is stored or found too large
+	skip bool
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.skip {
+		return n, err
+	}
+	b.buf.Write(p[:n])
+	if b.cache.maxBytes > 0 && int64(b.buf.Len()) > b.cache.maxBytes {
+		b.skip = true
+		b.buf = bytes.Buffer{}
+	} else if err == io.EOF {
+		b.skip = true
+		b.entry.Body = b.buf.Bytes()
+		b.cache.Put(b.entry)
+	}
+	return n, err
+}
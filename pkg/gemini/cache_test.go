@@ -0,0 +1,161 @@
+package gemini_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jasmaa/hikawa/pkg/gemini"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveCounted serves a page and counts the requests for it.
+func serveCounted(t *testing.T, hits *int32) string {
+	return serveFunc(t, func(requestUrl string) string {
+		atomic.AddInt32(hits, 1)
+		return "20 text/gemini\r\npage"
+	})
+}
+
+// TestCacheHit tests returning a page seen before without a request.
+func TestCacheHit(t *testing.T) {
+	var hits int32
+	base := serveCounted(t, &hits)
+	client := gemini.MakeClient()
+	for i := 0; i < 2; i++ {
+		resp, err := client.NavigatePage(base + "/")
+		if assert.Nil(t, err) {
+			data, _ := resp.Response.ReadAll(0)
+			assert.Equal(t, "page", string(data))
+			assert.Equal(t, i == 1, resp.Cached)
+			assert.Equal(t, "text/gemini", resp.MediaType.String())
+		}
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+// TestCacheReload tests skipping the cache on reload.
+func TestCacheReload(t *testing.T) {
+	var hits int32
+	base := serveCounted(t, &hits)
+	client := gemini.MakeClient()
+	resp, err := client.NavigatePage(base + "/")
+	if assert.Nil(t, err) {
+		resp.Response.ReadAll(0)
+	}
+	resp, err = client.ReloadPage(base + "/")
+	if assert.Nil(t, err) {
+		resp.Response.ReadAll(0)
+		assert.False(t, resp.Cached)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+// TestCacheUnreadBody tests not caching a body that was not read to the end.
+func TestCacheUnreadBody(t *testing.T) {
+	var hits int32
+	base := serveCounted(t, &hits)
+	client := gemini.MakeClient()
+	for i := 0; i < 2; i++ {
+		resp, err := client.NavigatePage(base + "/")
+		if assert.Nil(t, err) {
+			resp.Response.Body.Close()
+			assert.False(t, resp.Cached)
+		}
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+// TestCacheDisabled tests requesting every time without a cache.
+func TestCacheDisabled(t *testing.T) {
+	var hits int32
+	base := serveCounted(t, &hits)
+	client := gemini.MakeClient()
+	client.Cache = nil
+	for i := 0; i < 2; i++ {
+		resp, err := client.NavigatePage(base + "/")
+		if assert.Nil(t, err) {
+			resp.Response.ReadAll(0)
+		}
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+// TestCacheNormalizedKey tests looking up entries by normalized url.
+func TestCacheNormalizedKey(t *testing.T) {
+	cache := gemini.NewResponseCache(0, 0)
+	cache.Put(gemini.CacheEntry{Url: "GEMINI://Example.org:1965/a#top", Body: []byte("a")})
+	entry, ok := cache.Get("gemini://example.org/a")
+	if assert.True(t, ok) {
+		assert.Equal(t, "a", string(entry.Body))
+	}
+}
+
+// TestCacheTTL tests expiring old entries.
+func TestCacheTTL(t *testing.T) {
+	cache := gemini.NewResponseCache(0, time.Minute)
+	cache.Put(gemini.CacheEntry{Url: "gemini://example.org/old", Stored: time.Now().Add(-time.Hour)})
+	cache.Put(gemini.CacheEntry{Url: "gemini://example.org/new"})
+	_, ok := cache.Get("gemini://example.org/old")
+	assert.False(t, ok)
+	_, ok = cache.Get("gemini://example.org/new")
+	assert.True(t, ok)
+}
+
+// TestCacheEvictsLeastRecentlyUsed tests evicting entries once over the size limit.
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := gemini.NewResponseCache(10, 0)
+	cache.Put(gemini.CacheEntry{Url: "gemini://example.org/a", Body: []byte("aaaa")})
+	cache.Put(gemini.CacheEntry{Url: "gemini://example.org/b", Body: []byte("bbbb")})
+	cache.Get("gemini://example.org/a")
+	cache.Put(gemini.CacheEntry{Url: "gemini://example.org/c", Body: []byte("cccc")})
+
+	_, ok := cache.Get("gemini://example.org/b")
+	assert.False(t, ok)
+	_, ok = cache.Get("gemini://example.org/a")
+	assert.True(t, ok)
+	_, ok = cache.Get("gemini://example.org/c")
+	assert.True(t, ok)
+	assert.Equal(t, int64(8), cache.Size())
+}
+
+// TestCacheTooLarge tests not storing entries over the size limit.
+func TestCacheTooLarge(t *testing.T) {
+	cache := gemini.NewResponseCache(3, 0)
+	cache.Put(gemini.CacheEntry{Url: "gemini://example.org/big", Body: []byte("big body")})
+	_, ok := cache.Get("gemini://example.org/big")
+	assert.False(t, ok)
+	assert.Equal(t, int64(0), cache.Size())
+}
+
+// TestOpenResponseCachePersist tests loading cached responses from disk.
+func TestOpenResponseCachePersist(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := gemini.OpenResponseCache(dir, 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cache.Put(gemini.CacheEntry{
+		Url:    "gemini://example.org/",
+		Header: gemini.ResponseHeader{Status: gemini.STATUS_SUCCESS, Meta: "text/gemini; lang=en"},
+		Body:   []byte("# Hello\r\n"),
+	})
+	assert.Nil(t, err)
+	cache.Put(gemini.CacheEntry{
+		Url:    "gemini://example.org/removed",
+		Header: gemini.ResponseHeader{Status: gemini.STATUS_SUCCESS, Meta: "text/plain"},
+	})
+	cache.Remove("gemini://example.org/removed")
+
+	reopened, err := gemini.OpenResponseCache(dir, 0, time.Hour)
+	if assert.Nil(t, err) {
+		entry, ok := reopened.Get("gemini://example.org/")
+		if assert.True(t, ok) {
+			assert.Equal(t, gemini.STATUS_SUCCESS, entry.Header.Status)
+			assert.Equal(t, "text/gemini; lang=en", entry.Header.Meta)
+			assert.Equal(t, "# Hello\r\n", string(entry.Body))
+		}
+		_, ok = reopened.Get("gemini://example.org/removed")
+		assert.False(t, ok)
+	}
+}
@@ -1,9 +1,10 @@
 package gemini
 
 import (
+	"context"
 	"errors"
-	"net/url"
-	"strings"
+	"fmt"
+	"sync"
 	"time"
 )
 
@@ -12,121 +13,321 @@ type Client struct {
 	Timeout      time.Duration
 	MaxRetries   int
 	MaxRedirects int
+	// KnownHosts pins server certificates. If nil, certificates are not checked.
+	KnownHosts KnownHosts
+	// TrustNewHosts pins certificates from new hosts instead of returning UnknownHostError.
+	TrustNewHosts bool
+	// Identities holds client certificates. If nil, no certificate is presented.
+	Identities Identities
+	// RedirectPolicy decides which redirects are followed. If nil, all redirects are followed.
+	RedirectPolicy RedirectPolicy
+	// PermanentRedirects records 31 redirects. If nil, they are not recorded.
+	PermanentRedirects *RedirectCache
+	// RetryPolicy decides which failures are retried, up to MaxRetries times.
+	RetryPolicy RetryPolicy
+	// OnRetry is called before waiting to retry, if set.
+	OnRetry func(event RetryEvent)
+	// Transport sends requests. If nil, DefaultTransport is used.
+	Transport Transport
+	// Cache holds success responses. If nil, responses are not cached.
+	Cache *ResponseCache
 }
 
 // ClientResponse is a high-level client response.
 type ClientResponse struct {
-	Response  *Response
-	Url       string
-	MimeTypes map[string]bool
+	Response *Response
+	Url      string
+	// MediaType is parsed from the meta of a success response.
+	MediaType MediaType
+	// Charset is the charset the body was sent in. Text bodies are decoded to UTF-8.
+	Charset string
+	// Lang is the language of the body, if given.
+	Lang string
+	// Identity is the client certificate presented for Url, if any.
+	Identity *Identity
+	// Redirects are the redirects followed to reach Url, in order.
+	Redirects []Redirect
+	// PendingRedirect is a redirect from Url that the RedirectPolicy asked about.
+	PendingRedirect *Redirect
+	// Cached is set when the response came from the client Cache.
+	Cached bool
 }
 
 // MakeClient makes the default client
 func MakeClient() Client {
 	return Client{
-		Timeout:      7 * time.Second,
-		MaxRetries:   3,
-		MaxRedirects: 5,
+		Timeout:            7 * time.Second,
+		MaxRetries:         3,
+		MaxRedirects:       5,
+		KnownHosts:         NewMemoryKnownHosts(),
+		TrustNewHosts:      true,
+		Identities:         NewMemoryIdentities(),
+		RedirectPolicy:     DefaultRedirectPolicy,
+		PermanentRedirects: NewRedirectCache(),
+		RetryPolicy:        DefaultRetryPolicy(),
+		Cache:              NewResponseCache(32<<20, 10*time.Minute),
 	}
 }
 
+// identityFor gets the identity to present for rawurl.
+func (c *Client) identityFor(rawurl string) (*Identity, bool) {
+	if c.Identities == nil {
+		return nil, false
+	}
+	return c.Identities.Lookup(rawurl)
+}
+
+// transport gets the Transport used by the client.
+func (c *Client) transport() Transport {
+	if c.Transport == nil {
+		return DefaultTransport
+	}
+	return c.Transport
+}
+
+// DefaultClient is the client used by Request.
+var DefaultClient = MakeClient()
+
 // NavigatePage gets the new url and page content pointed at by `url`.
 func (c *Client) NavigatePage(rawurl string) (*ClientResponse, error) {
-	type result struct {
-		Response *Response
-		Err      error
-	}
-	resChan := make(chan result, 1)
+	return c.NavigatePageContext(context.Background(), rawurl)
+}
 
-	go func() {
-		tries := 0
-		redirects := 0
-		for tries-1 < c.MaxRetries && redirects < c.MaxRedirects {
-			resp, err := Request(rawurl)
-			if err != nil {
-				resChan <- result{Err: err}
-				return
+// NavigatePageContext gets the new url and page content pointed at by `url`.
+// The client Timeout applies to each request until its header is received, while
+// ctx applies to the whole navigation including the body. When ctx is done, the
+// request is abandoned and ctx.Err() returned. Failure statuses, after any
+// retries, are returned as a *StatusError. Pages in the client Cache are
+// returned without a request.
+func (c *Client) NavigatePageContext(ctx context.Context, rawurl string) (*ClientResponse, error) {
+	return c.navigate(ctx, rawurl, false)
+}
+
+// ReloadPage gets the page pointed at by `url` like NavigatePage, skipping the cache.
+func (c *Client) ReloadPage(rawurl string) (*ClientResponse, error) {
+	return c.ReloadPageContext(context.Background(), rawurl)
+}
+
+// ReloadPageContext gets the page pointed at by `url` like NavigatePageContext,
+// skipping the cache. The new response is still stored in the cache.
+func (c *Client) ReloadPageContext(ctx context.Context, rawurl string) (*ClientResponse, error) {
+	return c.navigate(ctx, rawurl, true)
+}
+
+// navigate gets the page pointed at by rawurl, reading the cache unless bypassCache is set.
+func (c *Client) navigate(ctx context.Context, rawurl string, bypassCache bool) (*ClientResponse, error) {
+	rawurl, err := Normalize(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	rawurl = c.skipPermanentRedirects(rawurl)
+	clientResp := &ClientResponse{}
+	tries := 0
+	for {
+		if !bypassCache && c.cacheable(rawurl) {
+			if entry, ok := c.Cache.Get(rawurl); ok {
+				clientResp.Response = entry.Response()
+				clientResp.Url = rawurl
+				clientResp.Cached = true
+				return c.setContent(clientResp), nil
 			}
-			switch resp.Header.Status / 10 {
-			case 1:
-				// 1X Input
-				resChan <- result{Response: resp}
-				tries++
-				return
-			case 2:
-				// 2X Success
-				resChan <- result{Response: resp}
+		}
+
+		resp, err := c.requestWithTimeout(ctx, rawurl)
+		if err != nil {
+			if tries < c.MaxRetries && ctx.Err() == nil && c.RetryPolicy.ShouldRetryError(err) {
 				tries++
-				return
-			case 3:
-				// 3X Redirect
-				u, _ := url.ParseRequestURI(resp.Header.Meta)
-				if len(u.Scheme) == 0 {
-					// Relative url
-					prevUrl, _ := url.ParseRequestURI(rawurl)
-					prevUrl.Path = resp.Header.Meta
-					rawurl = prevUrl.String()
-				} else {
-					// Absolute url
-					rawurl = resp.Header.Meta
+				if err := c.waitToRetry(ctx, rawurl, tries, nil, err); err != nil {
+					return nil, err
 				}
-				redirects++
-			case 4:
-				// 4X Temporary Failure
-				resChan <- result{Response: resp}
-				tries++
-			case 5:
-				// 5X Permanent Failure
-				resChan <- result{Response: resp}
-				tries++
-				return
-			case 6:
-				// 6X Client Certificate Required
-				resChan <- result{Response: resp}
-				tries++
-				return
-			default:
-				// Unrecognized status code
-				resChan <- result{
-					Err: errors.New("unrecognized status code"),
+				continue
+			}
+			return nil, err
+		}
+		clientResp.Response = resp
+		clientResp.Url = rawurl
+		switch resp.Header.Status.Class() {
+		case STATUS_CLASS_INPUT:
+			return c.setContent(clientResp), nil
+		case STATUS_CLASS_SUCCESS:
+			if c.cacheable(rawurl) {
+				resp.Body = &cachingBody{
+					ReadCloser: resp.Body,
+					cache:      c.Cache,
+					entry:      CacheEntry{Url: rawurl, Header: resp.Header, Stored: time.Now()},
 				}
-				tries++
-				return
 			}
-
-			// Sleep before retrying
-			time.Sleep(100 * time.Millisecond)
+			return c.setContent(clientResp), nil
+		case STATUS_CLASS_REDIRECT:
+			redirect, err := resolveRedirect(rawurl, resp.Header.Status, resp.Header.Meta)
+			if err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+			action := REDIRECT_FOLLOW
+			if c.RedirectPolicy != nil {
+				action = c.RedirectPolicy(redirect)
+			}
+			switch action {
+			case REDIRECT_DENY:
+				resp.Body.Close()
+				return nil, fmt.Errorf("%w: %s to %s", ErrRedirectDenied, redirect.From, redirect.To)
+			case REDIRECT_ASK:
+				clientResp.PendingRedirect = &redirect
+				return c.setContent(clientResp), nil
+			}
+			resp.Body.Close()
+			if len(clientResp.Redirects) >= c.MaxRedirects {
+				return nil, errors.New("exceeded maximum number of redirects")
+			}
+			if redirect.IsPermanent() && c.PermanentRedirects != nil {
+				c.PermanentRedirects.Record(redirect)
+			}
+			clientResp.Redirects = append(clientResp.Redirects, redirect)
+			rawurl = redirect.To
+		case STATUS_CLASS_TEMPORARY_FAILURE:
+			resp.Body.Close()
+			if tries >= c.MaxRetries || !c.RetryPolicy.ShouldRetryStatus(resp.Header.Status) {
+				return nil, newStatusError(resp, rawurl)
+			}
+			tries++
+			if err := c.waitToRetry(ctx, rawurl, tries, resp, nil); err != nil {
+				return nil, err
+			}
+		case STATUS_CLASS_PERMANENT_FAILURE, STATUS_CLASS_CLIENT_CERTIFICATE:
+			resp.Body.Close()
+			return nil, newStatusError(resp, rawurl)
+		default:
+			resp.Body.Close()
+			return nil, fmt.Errorf("unrecognized status code: %d", int(resp.Header.Status))
 		}
+	}
+}
 
-		if tries-1 >= c.MaxRetries {
-			resChan <- result{
-				Err: errors.New("exceeded maximum number of retries"),
-			}
-		} else if redirects > -c.MaxRedirects {
-			resChan <- result{
-				Err: errors.New("exceeded maximum number of redirects"),
+// newStatusError makes the error for a failure response from rawurl.
+func newStatusError(resp *Response, rawurl string) *StatusError {
+	return &StatusError{
+		Status: resp.Header.Status,
+		Meta:   resp.Header.Meta,
+		Url:    rawurl,
+	}
+}
+
+// requestWithTimeout requests rawurl, giving up with context.DeadlineExceeded
+// if the header does not arrive within the client Timeout. A header that
+// arrives in time is kept even if the timer fires while it is returned.
+func (c *Client) requestWithTimeout(parent context.Context, rawurl string) (*Response, error) {
+	ctx, cancel := context.WithCancel(parent)
+	var mu sync.Mutex
+	headerDone := false
+	if c.Timeout > 0 {
+		timer := time.AfterFunc(c.Timeout, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if !headerDone {
+				cancel()
 			}
+		})
+		defer timer.Stop()
+	}
+
+	resp, err := c.RequestContext(ctx, rawurl)
+	mu.Lock()
+	headerDone = true
+	mu.Unlock()
+	if err == nil && ctx.Err() != nil {
+		// The timer or parent cancelled the request as the header arrived
+		resp.Body.Close()
+		err = ctx.Err()
+	}
+	if err != nil {
+		timedOut := ctx.Err() != nil && parent.Err() == nil
+		cancel()
+		if timedOut {
+			return nil, context.DeadlineExceeded
 		}
-	}()
+		return nil, err
+	}
+
+	respBody := resp.Body
+	resp.Body = &body{Reader: respBody, close: func() error {
+		defer cancel()
+		return respBody.Close()
+	}}
+	return resp, nil
+}
+
+// waitToRetry reports a retry and waits for its delay or for ctx to be done.
+func (c *Client) waitToRetry(ctx context.Context, rawurl string, attempt int, resp *Response, err error) error {
+	event := RetryEvent{
+		Url:     rawurl,
+		Attempt: attempt,
+		Delay:   c.RetryPolicy.Delay(attempt, resp),
+		Err:     err,
+	}
+	if resp != nil {
+		event.Status = resp.Header.Status
+	}
+	if c.OnRetry != nil {
+		c.OnRetry(event)
+	}
 
+	timer := time.NewTimer(event.Delay)
+	defer timer.Stop()
 	select {
-	case respRes := <-resChan:
-		if respRes.Err != nil {
-			return nil, respRes.Err
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cacheable checks if responses for rawurl can be cached. Pages requested with
+// an identity are not cached since they may be personal.
+func (c *Client) cacheable(rawurl string) bool {
+	if c.Cache == nil {
+		return false
+	}
+	_, hasIdentity := c.identityFor(rawurl)
+	return !hasIdentity
+}
+
+// skipPermanentRedirects follows recorded permanent redirects from rawurl.
+func (c *Client) skipPermanentRedirects(rawurl string) string {
+	if c.PermanentRedirects == nil {
+		return rawurl
+	}
+	for i := 0; i < c.MaxRedirects; i++ {
+		to, ok := c.PermanentRedirects.Lookup(rawurl)
+		if !ok {
+			break
 		}
-		mimeTypes := make(map[string]bool)
-		if respRes.Response.Header.Status == 20 {
-			// Parse MIME types from meta
-			for _, mime := range strings.Split(respRes.Response.Header.Meta, ";") {
-				mimeTypes[mime] = true
-			}
+		rawurl = to
+	}
+	return rawurl
+}
+
+// setContent sets the identity and media type of a response and returns it.
+// Text bodies in other charsets are decoded to UTF-8.
+func (c *Client) setContent(clientResp *ClientResponse) *ClientResponse {
+	clientResp.Identity, _ = c.identityFor(clientResp.Url)
+	resp := clientResp.Response
+	if !resp.Header.Status.IsSuccess() {
+		return clientResp
+	}
+
+	mediaType, err := ParseMediaType(resp.Header.Meta)
+	if err != nil {
+		return clientResp
+	}
+	clientResp.MediaType = mediaType
+	clientResp.Charset = mediaType.Charset()
+	clientResp.Lang = mediaType.Lang()
+	if mediaType.Type == "text" {
+		decoded, err := DecodeCharset(resp.Body, clientResp.Charset)
+		if err == nil {
+			resp.Body = &body{Reader: decoded, close: resp.Body.Close}
 		}
-		return &ClientResponse{
-			Response:  respRes.Response,
-			Url:       rawurl,
-			MimeTypes: mimeTypes,
-		}, nil
-	case <-time.After(c.Timeout):
-		return nil, errors.New("request timed out")
 	}
+	return clientResp
 }
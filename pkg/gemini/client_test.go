@@ -0,0 +1,139 @@
+package gemini_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jasmaa/hikawa/internal/testcert"
+	"github.com/jasmaa/hikawa/internal/testserver"
+	"github.com/jasmaa/hikawa/pkg/gemini"
+	"github.com/jasmaa/hikawa/pkg/gemini/server"
+	"github.com/stretchr/testify/assert"
+)
+
+// readRequest reads a request line without its CRLF.
+func readRequest(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	return strings.TrimSuffix(line, "\r\n"), err
+}
+
+// serveStalled accepts connections and never responds.
+func serveStalled(t *testing.T) string {
+	done := make(chan struct{})
+	base := "gemini://" + testserver.TLS(t, testcert.New(t, time.Now().Add(time.Hour)), func(conn *tls.Conn) {
+		conn.Handshake()
+		<-done
+	})
+	t.Cleanup(func() { close(done) })
+	return base + "/"
+}
+
+// TestRequestContextCancel tests abandoning a stalled request on cancel.
+func TestRequestContextCancel(t *testing.T) {
+	rawurl := serveStalled(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	client := gemini.MakeClient()
+	_, err := client.RequestContext(ctx, rawurl)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// TestNavigatePageTimeout tests the client timeout on a stalled request.
+func TestNavigatePageTimeout(t *testing.T) {
+	rawurl := serveStalled(t)
+	client := gemini.MakeClient()
+	client.Timeout = 50 * time.Millisecond
+	start := time.Now()
+	_, err := client.NavigatePageContext(context.Background(), rawurl)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+// TestNavigatePageStreamsPastTimeout tests that the timeout does not cut off a slow body.
+func TestNavigatePageStreamsPastTimeout(t *testing.T) {
+	base := "gemini://" + testserver.TLS(t, testcert.New(t, time.Now().Add(time.Hour)), func(conn *tls.Conn) {
+		readRequest(bufio.NewReader(conn))
+		conn.Write([]byte("20 text/gemini\r\n" + strings.Repeat("a", 1024)))
+		time.Sleep(150 * time.Millisecond)
+		conn.Write([]byte("end"))
+	})
+
+	client := gemini.MakeClient()
+	client.Timeout = 50 * time.Millisecond
+	resp, err := client.NavigatePage(base + "/")
+	if assert.Nil(t, err) {
+		data, err := resp.Response.ReadAll(0)
+		assert.Nil(t, err)
+		assert.Equal(t, strings.Repeat("a", 1024)+"end", string(data))
+	}
+}
+
+// serveResponse serves a raw Gemini response to every request and returns the url.
+func serveResponse(t *testing.T, rawresp string) string {
+	return serveFunc(t, func(requestUrl string) string {
+		return rawresp
+	}) + "/"
+}
+
+// TestNavigatePageDecodesCharset tests decoding a latin-1 page to UTF-8.
+func TestNavigatePageDecodesCharset(t *testing.T) {
+	rawurl := serveResponse(t, "20 text/gemini; charset=ISO-8859-1; lang=fr\r\n# Caf\xe9")
+	client := gemini.MakeClient()
+	resp, err := client.NavigatePage(rawurl)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "text/gemini", resp.MediaType.String())
+		assert.Equal(t, "iso-8859-1", resp.Charset)
+		assert.Equal(t, "fr", resp.Lang)
+		data, err := resp.Response.ReadAll(0)
+		assert.Nil(t, err)
+		assert.Equal(t, "# Café", string(data))
+	}
+}
+
+// serveFunc serves Gemini responses from handler until the test ends and returns the base url.
+func serveFunc(t *testing.T, handler func(requestUrl string) string) string {
+	return "gemini://" + testserver.TLS(t, testcert.New(t, time.Now().Add(time.Hour)), func(conn *tls.Conn) {
+		requestUrl, err := readRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		conn.Write([]byte(handler(requestUrl)))
+	})
+}
+
+// serveCapsule serves handler with an in-process server and returns the base url.
+func serveCapsule(t *testing.T, handler server.Handler) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &server.Server{
+		Handler:   handler,
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{testcert.New(t, time.Now().Add(time.Hour))}},
+	}
+	go srv.Serve(listener)
+	t.Cleanup(func() { srv.Close() })
+	return "gemini://" + listener.Addr().String()
+}
+
+// TestNavigatePageCapsule tests following a server redirect to a directory index.
+func TestNavigatePageCapsule(t *testing.T) {
+	mux := server.NewServeMux()
+	mux.Handle("/docs/", server.FileServer(fstest.MapFS{
+		"docs/index.gmi": {Data: []byte("# Docs\n")},
+	}))
+	base := serveCapsule(t, mux)
+	client := gemini.MakeClient()
+	resp, err := client.NavigatePage(base + "/docs")
+	if assert.Nil(t, err) {
+		assert.Equal(t, base+"/docs/", resp.Url)
+		data, _ := resp.Response.ReadAll(0)
+		assert.Equal(t, "# Docs\n", string(data))
+	}
+}
@@ -1,127 +1,300 @@
 package gemini
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
 	"net/url"
-	"strconv"
 	"strings"
 )
 
 // ResponseHeader is a Gemini response header.
 type ResponseHeader struct {
-	Status int
+	Status Status
 	Meta   string
 }
 
 // Response is a Gemini response.
 type Response struct {
 	Header ResponseHeader
-	Body   string
+	// Body streams the response body. It is empty for non-2X statuses.
+	// The caller must close Body to release the connection.
+	Body io.ReadCloser
 }
 
-// Request requests with a url and returns a Response.
-func Request(requestUrl string) (*Response, error) {
-	u, err := url.ParseRequestURI(requestUrl)
+// ErrBodyTooLarge is returned by ReadAll when a body is larger than the limit.
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// ReadAll reads and closes the body, reading at most maxBytes.
+// ErrBodyTooLarge is returned with the first maxBytes if the body is longer.
+// A maxBytes of 0 or less reads the whole body.
+func (r *Response) ReadAll(maxBytes int64) ([]byte, error) {
+	defer r.Body.Close()
+	if maxBytes <= 0 {
+		return io.ReadAll(r.Body)
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBytes+1))
 	if err != nil {
-		return nil, err
+		return data, err
 	}
-	u.RawQuery = url.PathEscape(u.RawQuery)
-
-	if u.Scheme != "gemini" {
-		return nil, errors.New("scheme was not gemini")
+	if int64(len(data)) > maxBytes {
+		return data[:maxBytes], ErrBodyTooLarge
 	}
+	return data, nil
+}
+
+// body is a response body read from a connection.
+type body struct {
+	io.Reader
+	// ctx is the request context, if any
+	ctx   context.Context
+	close func() error
+}
 
-	var host, port string
-	hostport := strings.Split(u.Host, ":")
-	if len(hostport) < 1 {
-		return nil, errors.New("no hostname provided")
+func (b *body) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err != nil && err != io.EOF && b.ctx != nil && b.ctx.Err() != nil {
+		return n, b.ctx.Err()
 	}
-	host = hostport[0]
-	if len(hostport) > 1 {
-		port = hostport[1]
-	} else {
-		port = "1965"
+	return n, err
+}
+
+// Close closes the connection.
+func (b *body) Close() error {
+	if b.close == nil {
+		return nil
 	}
+	return b.close()
+}
 
-	conf := &tls.Config{
-		InsecureSkipVerify: true,
+// Request requests with a url using DefaultClient and returns a Response.
+func Request(requestUrl string) (*Response, error) {
+	return DefaultClient.Request(requestUrl)
+}
+
+// RequestContext requests with a url using DefaultClient and returns a Response.
+func RequestContext(ctx context.Context, requestUrl string) (*Response, error) {
+	return DefaultClient.RequestContext(ctx, requestUrl)
+}
+
+// Request requests with a url and returns a Response.
+func (c *Client) Request(requestUrl string) (*Response, error) {
+	return c.RequestContext(context.Background(), requestUrl)
+}
+
+// RequestContext requests with a url using the client Transport and returns a Response.
+// Server certificates are checked against the client's KnownHosts and
+// the client's identity for the url, if any, is presented.
+// The connection is closed and ctx.Err() returned when ctx is done.
+func (c *Client) RequestContext(ctx context.Context, requestUrl string) (*Response, error) {
+	return c.request(ctx, requestUrl, nil)
+}
+
+// RequestBodyContext requests with a url like RequestContext and sends body
+// after the request line, as Titan uploads do. titan urls are allowed and
+// present the identity of the matching gemini url.
+func (c *Client) RequestBodyContext(ctx context.Context, requestUrl string, body io.Reader) (*Response, error) {
+	return c.request(ctx, requestUrl, body)
+}
+
+// request requests with a url and sends body after the request line, if any.
+func (c *Client) request(ctx context.Context, requestUrl string, body io.Reader) (*Response, error) {
+	normalized, err := Normalize(requestUrl)
+	if err != nil {
+		return nil, err
 	}
-	conn, err := tls.Dial("tcp", fmt.Sprintf("%s:%s", host, port), conf)
+	u, err := url.Parse(normalized)
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
 
-	_, err = conn.Write([]byte(u.String() + "\r\n"))
+	if body != nil && u.Scheme != "gemini" && u.Scheme != "titan" {
+		return nil, errors.New("scheme was not gemini or titan")
+	}
+	if body == nil && u.Scheme != "gemini" {
+		return nil, errors.New("scheme was not gemini")
+	}
+
+	host, addr, err := requestHost(u)
 	if err != nil {
 		return nil, err
 	}
+	if len(u.String()) > MAX_REQUEST_LENGTH {
+		return nil, fmt.Errorf("%w: %d bytes", ErrRequestTooLong, len(u.String()))
+	}
 
-	resp, err := ReadResponse(conn)
-	return resp, err
+	conf := &tls.Config{
+		ServerName: host,
+		// Certificates are verified with TOFU instead of CAs
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if c.KnownHosts == nil {
+				return nil
+			}
+			return verifyKnownHost(c.KnownHosts, addr, rawCerts, c.TrustNewHosts)
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if identity, ok := c.identityFor(identityUrl(u)); ok {
+				return &identity.Certificate, nil
+			}
+			return &tls.Certificate{}, nil
+		},
+	}
+	return c.transport().RoundTrip(ctx, &TransportRequest{
+		Url:       u,
+		Addr:      addr,
+		TLSConfig: conf,
+		Body:      body,
+	})
 }
 
+// identityUrl gets the url identities are looked up with for u. Titan urls
+// use the gemini url of the same page, without upload parameters.
+func identityUrl(u *url.URL) string {
+	if u.Scheme != "titan" {
+		return u.String()
+	}
+	geminiUrl := *u
+	geminiUrl.Scheme = "gemini"
+	geminiUrl.RawPath = ""
+	geminiUrl.Path, _, _ = strings.Cut(u.Path, ";")
+	return geminiUrl.String()
+}
+
+// MAX_META_LENGTH is the maximum length of a response meta in bytes.
+const MAX_META_LENGTH = 1024
+
+var (
+	// ErrInvalidStatus is returned when a response does not start with a two digit status.
+	ErrInvalidStatus = errors.New("response status is not two digits")
+	// ErrMalformedHeader is returned when the status is not followed by a space or CRLF.
+	ErrMalformedHeader = errors.New("response status not followed by space or CRLF")
+	// ErrMetaTooLong is returned when the meta is longer than 1024 bytes.
+	ErrMetaTooLong = errors.New("meta greater than 1024 bytes")
+	// ErrMissingCRLF is returned when the header line ends in a bare CR or LF.
+	ErrMissingCRLF = errors.New("response header not terminated by CRLF")
+	// ErrMetaBOM is returned when the meta starts with a UTF-8 byte order mark.
+	ErrMetaBOM = errors.New("response meta starts with a byte order mark")
+)
+
 // ReadResponse reads Response from connection reader.
+// conn is wrapped in a bufio.Reader unless it is one, which may read past the
+// header, so the body is streamed from that same reader. Closing the body
+// closes conn if it is an io.Closer.
 func ReadResponse(conn io.Reader) (*Response, error) {
-	// Read status
-	buffer := make([]byte, 2)
-	_, err := io.ReadFull(conn, buffer)
-	if err != nil {
-		return nil, err
+	reader, ok := conn.(*bufio.Reader)
+	if !ok {
+		reader = bufio.NewReader(conn)
 	}
-	status, err := strconv.Atoi(string(buffer[:2]))
+
+	header, err := readHeader(reader)
 	if err != nil {
 		return nil, err
 	}
 
-	buffer = make([]byte, 1)
-	_, err = io.ReadFull(conn, buffer)
-	if err != nil {
-		return nil, err
+	// Stream body on 2X status
+	respBody := &body{Reader: bytes.NewReader(nil)}
+	if header.Status.IsSuccess() {
+		respBody.Reader = reader
 	}
-	if buffer[0] != 0x20 {
-		// TODO: Fix status reads for responses without meta
-		return nil, errors.New("no meta found")
+	if closer, ok := conn.(io.Closer); ok {
+		respBody.close = closer.Close
 	}
 
-	// Read meta, meta (1024) + CRLF (2)
-	buffer = make([]byte, 1026)
-	meta, beginIdx, endIdx, err := readMeta(conn, buffer)
+	return &Response{
+		Header: header,
+		Body:   respBody,
+	}, nil
+}
+
+// readHeader reads `<STATUS><SPACE><META><CR><LF>` where the space and meta are optional.
+func readHeader(reader io.ByteReader) (ResponseHeader, error) {
+	// Read status
+	var digits [2]byte
+	for i := range digits {
+		c, err := reader.ReadByte()
+		if err != nil {
+			if i > 0 && err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return ResponseHeader{}, err
+		}
+		if c < '0' || c > '9' {
+			return ResponseHeader{}, ErrInvalidStatus
+		}
+		digits[i] = c
+	}
+	status := Status(int(digits[0]-'0')*10 + int(digits[1]-'0'))
+
+	c, err := readHeaderByte(reader)
 	if err != nil {
-		return nil, err
+		return ResponseHeader{}, err
+	}
+	switch c {
+	case '\r':
+		// Empty meta
+		if err := readLF(reader); err != nil {
+			return ResponseHeader{}, err
+		}
+		return ResponseHeader{Status: status}, nil
+	case ' ':
+	default:
+		return ResponseHeader{}, ErrMalformedHeader
 	}
 
-	// Read body on 2X status
-	rawbody := make([]byte, len(buffer[beginIdx:endIdx]))
-	copy(rawbody, buffer[beginIdx:endIdx])
-	n := 0
-	if status/10 == 2 {
-		for err != io.EOF {
-			n, err = conn.Read(buffer)
-			rawbody = append(rawbody, buffer[:n]...)
+	// Read meta up to CRLF
+	meta := make([]byte, 0, 64)
+	for {
+		c, err := readHeaderByte(reader)
+		if err != nil {
+			return ResponseHeader{}, err
+		}
+		if c == '\r' {
+			if err := readLF(reader); err != nil {
+				return ResponseHeader{}, err
+			}
+			break
+		}
+		if c == '\n' {
+			return ResponseHeader{}, ErrMissingCRLF
+		}
+		if len(meta) == MAX_META_LENGTH {
+			return ResponseHeader{}, ErrMetaTooLong
 		}
+		meta = append(meta, c)
+	}
+	if bytes.HasPrefix(meta, []byte("\xEF\xBB\xBF")) {
+		return ResponseHeader{}, ErrMetaBOM
 	}
 
-	return &Response{
-		Header: ResponseHeader{
-			Status: status,
-			Meta:   meta,
-		},
-		Body: string(rawbody),
+	return ResponseHeader{
+		Status: status,
+		Meta:   string(meta),
 	}, nil
 }
 
-func readMeta(conn io.Reader, buffer []byte) (string, int, int, error) {
-	n, _ := io.ReadFull(conn, buffer)
-	var idx int
-	for idx = 1; idx < n; idx++ {
-		if buffer[idx-1] == 0x0D && buffer[idx] == 0x0A {
-			meta := string(buffer[:idx-1])
-			return meta, idx + 1, n, nil
-		}
+// readHeaderByte reads a byte of a header that has already started.
+func readHeaderByte(reader io.ByteReader) (byte, error) {
+	c, err := reader.ReadByte()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return c, err
+}
+
+// readLF reads the LF after a CR.
+func readLF(reader io.ByteReader) error {
+	c, err := readHeaderByte(reader)
+	if err != nil {
+		return err
+	}
+	if c != '\n' {
+		return ErrMissingCRLF
 	}
-	return "", -1, -1, errors.New("meta greater than 1024 bytes")
+	return nil
 }
@@ -3,6 +3,7 @@ package gemini_test
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"strings"
 	"testing"
 
@@ -23,7 +24,9 @@ func TestReadResponseSuccess(t *testing.T) {
 	if assert.Nil(t, err) {
 		assert.Equal(t, status, resp.Header.Status)
 		assert.Equal(t, meta, resp.Header.Meta)
-		assert.Equal(t, body, resp.Body)
+		data, err := resp.ReadAll(0)
+		assert.Nil(t, err)
+		assert.Equal(t, body, string(data))
 	}
 }
 
@@ -58,6 +61,115 @@ func TestReadResponseEmptyMeta(t *testing.T) {
 	status := gemini.STATUS_SUCCESS
 	rawresp := fmt.Sprintf("%d\r\n", status)
 	conn := bytes.NewReader([]byte(rawresp))
-	_, err := gemini.ReadResponse(conn)
-	assert.NotNil(t, err)
+	resp, err := gemini.ReadResponse(conn)
+	if assert.Nil(t, err) {
+		assert.Equal(t, status, resp.Header.Status)
+		assert.Equal(t, "", resp.Header.Meta)
+	}
+}
+
+// TestReadResponseMeta1024 tests accepting meta of exactly 1024 bytes.
+func TestReadResponseMeta1024(t *testing.T) {
+	meta := strings.Repeat("a", 1024)
+	conn := bytes.NewReader([]byte(fmt.Sprintf("%d %s\r\n", gemini.STATUS_INPUT, meta)))
+	resp, err := gemini.ReadResponse(conn)
+	if assert.Nil(t, err) {
+		assert.Equal(t, meta, resp.Header.Meta)
+	}
+}
+
+// TestReadResponseMalformed tests the error for each malformed header.
+func TestReadResponseMalformed(t *testing.T) {
+	tests := []struct {
+		rawresp string
+		err     error
+	}{
+		{"2x text/gemini\r\n", gemini.ErrInvalidStatus},
+		{"+1 text/gemini\r\n", gemini.ErrInvalidStatus},
+		{"2 text/gemini\r\n", gemini.ErrInvalidStatus},
+		{"200 text/gemini\r\n", gemini.ErrMalformedHeader},
+		{"20\ttext/gemini\r\n", gemini.ErrMalformedHeader},
+		{"20 " + strings.Repeat("a", 1025) + "\r\n", gemini.ErrMetaTooLong},
+		{"20 text/gemini\n", gemini.ErrMissingCRLF},
+		{"20 text/gemini\rbody", gemini.ErrMissingCRLF},
+		{"20 \xEF\xBB\xBFtext/gemini\r\n", gemini.ErrMetaBOM},
+		{"20 text/gemini", io.ErrUnexpectedEOF},
+		{"2", io.ErrUnexpectedEOF},
+		{"", io.EOF},
+	}
+	for _, test := range tests {
+		_, err := gemini.ReadResponse(bytes.NewReader([]byte(test.rawresp)))
+		assert.ErrorIs(t, err, test.err, "%q", test.rawresp)
+	}
+}
+
+// TestReadResponseShortHeaderLive tests returning a short header without waiting for the connection to end.
+func TestReadResponseShortHeaderLive(t *testing.T) {
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	go writer.Write([]byte("51 \r\n"))
+	resp, err := gemini.ReadResponse(reader)
+	if assert.Nil(t, err) {
+		assert.Equal(t, gemini.STATUS_NOT_FOUND, resp.Header.Status)
+	}
+}
+
+// FuzzReadResponse tests that any header parses into a valid response or an error.
+func FuzzReadResponse(f *testing.F) {
+	f.Add([]byte("20 text/gemini\r\nbody"))
+	f.Add([]byte("20\r\n"))
+	f.Add([]byte("31 gemini://example.com/\r\n"))
+	f.Add([]byte("2x \r\n"))
+	f.Add([]byte("44 10\rx"))
+	f.Add([]byte("20 \xEF\xBB\xBF\r\n"))
+	f.Fuzz(func(t *testing.T, rawresp []byte) {
+		resp, err := gemini.ReadResponse(bytes.NewReader(rawresp))
+		if err != nil {
+			return
+		}
+		assert.True(t, resp.Header.Status >= 0 && resp.Header.Status <= 99)
+		assert.LessOrEqual(t, len(resp.Header.Meta), 1024)
+		assert.NotContains(t, resp.Header.Meta, "\r")
+		assert.NotContains(t, resp.Header.Meta, "\n")
+		data, err := resp.ReadAll(0)
+		assert.Nil(t, err)
+		if !resp.Header.Status.IsSuccess() {
+			assert.Empty(t, data)
+		}
+	})
+}
+
+// TestReadResponseStreamsBody tests reading the body before the connection ends.
+func TestReadResponseStreamsBody(t *testing.T) {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.Write([]byte("20 text/gemini\r\n" + strings.Repeat("a", 2000)))
+	}()
+	resp, err := gemini.ReadResponse(reader)
+	if assert.Nil(t, err) {
+		buffer := make([]byte, 2000)
+		n, err := io.ReadFull(resp.Body, buffer)
+		assert.Nil(t, err)
+		assert.Equal(t, 2000, n)
+		assert.Nil(t, resp.Body.Close())
+	}
+	writer.Close()
+}
+
+// TestReadAllLimit tests capping the body size.
+func TestReadAllLimit(t *testing.T) {
+	rawresp := fmt.Sprintf("%d text/plain\r\n%s", gemini.STATUS_SUCCESS, "0123456789")
+	resp, err := gemini.ReadResponse(bytes.NewReader([]byte(rawresp)))
+	if assert.Nil(t, err) {
+		data, err := resp.ReadAll(4)
+		assert.ErrorIs(t, err, gemini.ErrBodyTooLarge)
+		assert.Equal(t, "0123", string(data))
+	}
+
+	resp, err = gemini.ReadResponse(bytes.NewReader([]byte(rawresp)))
+	if assert.Nil(t, err) {
+		data, err := resp.ReadAll(10)
+		assert.Nil(t, err)
+		assert.Equal(t, "0123456789", string(data))
+	}
 }
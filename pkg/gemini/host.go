@@ -0,0 +1,45 @@
+package gemini
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// DEFAULT_PORT is the port used when a url has none.
+const DEFAULT_PORT = "1965"
+
+// requestHost gets the ASCII host and the host:port to dial for u.
+// Internationalized domain names are converted to punycode and u.Host is
+// rewritten to match so the request line carries the ASCII host.
+func requestHost(u *url.URL) (string, string, error) {
+	host := u.Hostname()
+	if len(host) == 0 {
+		return "", "", errors.New("no hostname provided")
+	}
+	port := u.Port()
+
+	// IPv6 literals, with or without a zone, are the only hosts with colons
+	if !strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		asciiHost, err := idna.Lookup.ToASCII(host)
+		if err != nil {
+			return "", "", err
+		}
+		host = asciiHost
+	}
+
+	if len(port) > 0 {
+		u.Host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	} else {
+		u.Host = host
+	}
+	if len(port) == 0 {
+		port = DEFAULT_PORT
+	}
+	return host, net.JoinHostPort(host, port), nil
+}
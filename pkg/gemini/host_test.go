@@ -0,0 +1,73 @@
+package gemini_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jasmaa/hikawa/pkg/gemini"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRequestHost tests the dial address, SNI and request line for different hosts.
+func TestRequestHost(t *testing.T) {
+	tests := []struct {
+		rawurl     string
+		addr       string
+		serverName string
+		requestUrl string
+	}{
+		{"gemini://example.org/", "example.org:1965", "example.org", "gemini://example.org/"},
+		{"gemini://example.org:1966/", "example.org:1966", "example.org", "gemini://example.org:1966/"},
+		{"gemini://127.0.0.1/", "127.0.0.1:1965", "127.0.0.1", "gemini://127.0.0.1/"},
+		{"gemini://127.0.0.1:1966/page", "127.0.0.1:1966", "127.0.0.1", "gemini://127.0.0.1:1966/page"},
+		{"gemini://[::1]/", "[::1]:1965", "::1", "gemini://[::1]/"},
+		{"gemini://[::1]:1966/", "[::1]:1966", "::1", "gemini://[::1]:1966/"},
+		{"gemini://[2001:db8::1]/page", "[2001:db8::1]:1965", "2001:db8::1", "gemini://[2001:db8::1]/page"},
+		{"gemini://bücher.example/", "xn--bcher-kva.example:1965", "xn--bcher-kva.example", "gemini://xn--bcher-kva.example/"},
+		{"gemini://BÜCHER.example:1966/", "xn--bcher-kva.example:1966", "xn--bcher-kva.example", "gemini://xn--bcher-kva.example:1966/"},
+		{"gemini://xn--bcher-kva.example/", "xn--bcher-kva.example:1965", "xn--bcher-kva.example", "gemini://xn--bcher-kva.example/"},
+	}
+	for _, test := range tests {
+		transport := &fakeTransport{rawresp: "20 text/gemini\r\n"}
+		client := gemini.MakeClient()
+		client.Transport = transport
+		resp, err := client.Request(test.rawurl)
+		if assert.Nil(t, err, test.rawurl) && assert.Len(t, transport.requests, 1) {
+			resp.Body.Close()
+			req := transport.requests[0]
+			assert.Equal(t, test.addr, req.Addr, test.rawurl)
+			assert.Equal(t, test.serverName, req.TLSConfig.ServerName, test.rawurl)
+			assert.Equal(t, test.requestUrl, req.Url.String(), test.rawurl)
+		}
+	}
+}
+
+// TestRequestHostInvalid tests erroring on missing and invalid hosts.
+func TestRequestHostInvalid(t *testing.T) {
+	for _, rawurl := range []string{
+		"gemini:///path",
+		"gemini://:1965/",
+		"gemini://bad_host.example/",
+	} {
+		client := gemini.MakeClient()
+		client.Transport = &fakeTransport{rawresp: "20 text/gemini\r\n"}
+		_, err := client.Request(rawurl)
+		assert.NotNil(t, err, rawurl)
+	}
+}
+
+// TestRequestIPv6 tests connecting to an IPv6 literal.
+func TestRequestIPv6(t *testing.T) {
+	base := serveFunc(t, func(requestUrl string) string {
+		return "20 text/gemini\r\n" + requestUrl
+	})
+	dialer := &redirectDialer{addr: strings.TrimPrefix(base, "gemini://")}
+	client := gemini.MakeClient()
+	client.Transport = &gemini.TLSTransport{Dialer: dialer}
+	resp, err := client.Request("gemini://[::1]:1966/")
+	if assert.Nil(t, err) {
+		data, _ := resp.ReadAll(0)
+		assert.Equal(t, "gemini://[::1]:1966/", string(data))
+		assert.Equal(t, []string{"[::1]:1966"}, dialer.asked)
+	}
+}
@@ -0,0 +1,353 @@
+package gemini
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyAlgorithm is the key algorithm for a generated identity.
+type KeyAlgorithm int
+
+const (
+	KEY_ALGORITHM_ECDSA KeyAlgorithm = iota
+	KEY_ALGORITHM_ED25519
+)
+
+// identityValidity is how long generated identities are valid for.
+const identityValidity = 5 * 365 * 24 * time.Hour
+
+// Identity is a named client certificate.
+type Identity struct {
+	Name        string
+	Certificate tls.Certificate
+}
+
+// NewIdentity generates an identity with a self-signed certificate
+// whose common name is name.
+func NewIdentity(name string, algorithm KeyAlgorithm) (*Identity, error) {
+	if err := validateIdentityName(name); err != nil {
+		return nil, err
+	}
+
+	var key crypto.Signer
+	var err error
+	switch algorithm {
+	case KEY_ALGORITHM_ECDSA:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KEY_ALGORITHM_ED25519:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, errors.New("unsupported key algorithm")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(identityValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{
+		Name: name,
+		Certificate: tls.Certificate{
+			Certificate: [][]byte{der},
+			PrivateKey:  key,
+			Leaf:        leaf,
+		},
+	}, nil
+}
+
+// Fingerprint gets the SHA-256 fingerprint of the identity certificate.
+func (i *Identity) Fingerprint() string {
+	return Fingerprint(i.Certificate.Leaf)
+}
+
+// validateIdentityName checks that a name can be used as an identity file name.
+func validateIdentityName(name string) error {
+	if len(strings.TrimSpace(name)) == 0 {
+		return errors.New("identity name is empty")
+	}
+	if name == "." || name == ".." || strings.ContainsAny(name, "/\\\r\n") {
+		return fmt.Errorf("invalid identity name: %q", name)
+	}
+	return nil
+}
+
+// inScope checks if rawurl is under the url prefix scope.
+func inScope(scope string, rawurl string) bool {
+	if !strings.HasPrefix(rawurl, scope) {
+		return false
+	}
+	if len(rawurl) == len(scope) || strings.HasSuffix(scope, "/") {
+		return true
+	}
+	next := rawurl[len(scope)]
+	return next == '/' || next == '?'
+}
+
+// Identities stores client certificates and the url prefixes they are used for.
+type Identities interface {
+	// Lookup gets the identity bound to the longest scope containing rawurl.
+	Lookup(rawurl string) (*Identity, bool)
+	// Get gets an identity by name.
+	Get(name string) (*Identity, bool)
+	// List lists identities sorted by name.
+	List() []*Identity
+	// Add adds an identity, replacing any identity with the same name.
+	Add(identity *Identity) error
+	// Bind uses the named identity for all urls under the scope url prefix.
+	Bind(name string, scope string) error
+}
+
+// MemoryIdentities is an in-memory Identities.
+type MemoryIdentities struct {
+	mu         sync.RWMutex
+	identities map[string]*Identity
+	scopes     map[string]string
+}
+
+// NewMemoryIdentities creates an empty MemoryIdentities.
+func NewMemoryIdentities() *MemoryIdentities {
+	return &MemoryIdentities{
+		identities: make(map[string]*Identity),
+		scopes:     make(map[string]string),
+	}
+}
+
+// Lookup gets the identity bound to the longest scope containing rawurl.
+func (m *MemoryIdentities) Lookup(rawurl string) (*Identity, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	bestScope := ""
+	for scope := range m.scopes {
+		if len(scope) > len(bestScope) && inScope(scope, rawurl) {
+			bestScope = scope
+		}
+	}
+	if len(bestScope) == 0 {
+		return nil, false
+	}
+	identity, ok := m.identities[m.scopes[bestScope]]
+	return identity, ok
+}
+
+// Get gets an identity by name.
+func (m *MemoryIdentities) Get(name string) (*Identity, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	identity, ok := m.identities[name]
+	return identity, ok
+}
+
+// List lists identities sorted by name.
+func (m *MemoryIdentities) List() []*Identity {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	identities := make([]*Identity, 0, len(m.identities))
+	for _, identity := range m.identities {
+		identities = append(identities, identity)
+	}
+	sort.Slice(identities, func(i, j int) bool {
+		return identities[i].Name < identities[j].Name
+	})
+	return identities
+}
+
+// Add adds an identity, replacing any identity with the same name.
+func (m *MemoryIdentities) Add(identity *Identity) error {
+	if err := validateIdentityName(identity.Name); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.identities[identity.Name] = identity
+	return nil
+}
+
+// Bind uses the named identity for all urls under the scope url prefix.
+func (m *MemoryIdentities) Bind(name string, scope string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bind(name, scope)
+}
+
+func (m *MemoryIdentities) bind(name string, scope string) error {
+	if _, ok := m.identities[name]; !ok {
+		return fmt.Errorf("no identity named %q", name)
+	}
+	if len(scope) == 0 {
+		return errors.New("identity scope is empty")
+	}
+	m.scopes[scope] = name
+	return nil
+}
+
+// FileIdentities is an Identities persisted to a directory.
+// Each identity is saved as `<name>.pem` holding its certificate and PKCS #8 key.
+// Scopes are saved in `scopes` with one `scope name` pair per line.
+type FileIdentities struct {
+	dir    string
+	memory *MemoryIdentities
+}
+
+// OpenFileIdentities loads identities from dir. A missing directory is treated as empty.
+func OpenFileIdentities(dir string) (*FileIdentities, error) {
+	f := &FileIdentities{
+		dir:    dir,
+		memory: NewMemoryIdentities(),
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		identity, err := readIdentity(path)
+		if err != nil {
+			return nil, err
+		}
+		f.memory.identities[identity.Name] = identity
+	}
+
+	scopesFile, err := os.Open(filepath.Join(dir, "scopes"))
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer scopesFile.Close()
+	scanner := bufio.NewScanner(scopesFile)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		scope, name, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			return nil, fmt.Errorf("malformed identity scope: %q", scanner.Text())
+		}
+		if err := f.memory.bind(name, scope); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// readIdentity reads an identity from a PEM file named after the identity.
+func readIdentity(path string) (*Identity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &Identity{
+		Name:        strings.TrimSuffix(filepath.Base(path), ".pem"),
+		Certificate: cert,
+	}, nil
+}
+
+// Lookup gets the identity bound to the longest scope containing rawurl.
+func (f *FileIdentities) Lookup(rawurl string) (*Identity, bool) {
+	return f.memory.Lookup(rawurl)
+}
+
+// Get gets an identity by name.
+func (f *FileIdentities) Get(name string) (*Identity, bool) {
+	return f.memory.Get(name)
+}
+
+// List lists identities sorted by name.
+func (f *FileIdentities) List() []*Identity {
+	return f.memory.List()
+}
+
+// Add adds an identity and saves it to disk.
+func (f *FileIdentities) Add(identity *Identity) error {
+	if err := f.memory.Add(identity); err != nil {
+		return err
+	}
+
+	keyDer, err := x509.MarshalPKCS8PrivateKey(identity.Certificate.PrivateKey)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, der := range identity.Certificate.Certificate {
+		pem.Encode(&b, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+	pem.Encode(&b, &pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})
+
+	if err := os.MkdirAll(f.dir, 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(f.dir, identity.Name+".pem"), []byte(b.String()), 0o600)
+}
+
+// Bind uses the named identity for all urls under the scope url prefix and saves the scopes.
+func (f *FileIdentities) Bind(name string, scope string) error {
+	f.memory.mu.Lock()
+	defer f.memory.mu.Unlock()
+	if err := f.memory.bind(name, scope); err != nil {
+		return err
+	}
+
+	scopes := make([]string, 0, len(f.memory.scopes))
+	for scope := range f.memory.scopes {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	var b strings.Builder
+	for _, scope := range scopes {
+		fmt.Fprintf(&b, "%s %s\n", scope, f.memory.scopes[scope])
+	}
+
+	if err := os.MkdirAll(f.dir, 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(f.dir, "scopes"), []byte(b.String()), 0o600)
+}
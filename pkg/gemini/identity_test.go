@@ -0,0 +1,119 @@
+package gemini_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jasmaa/hikawa/internal/testcert"
+	"github.com/jasmaa/hikawa/internal/testserver"
+	"github.com/jasmaa/hikawa/pkg/gemini"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveClientCertificate serves responses echoing the client certificate name.
+func serveClientCertificate(t *testing.T) string {
+	return "gemini://" + testserver.TLS(t, testcert.New(t, time.Now().Add(time.Hour)), func(conn *tls.Conn) {
+		readRequest(bufio.NewReader(conn))
+		certs := conn.ConnectionState().PeerCertificates
+		if len(certs) == 0 {
+			conn.Write([]byte("60 certificate required\r\n"))
+			return
+		}
+		conn.Write([]byte(fmt.Sprintf("20 text/gemini\r\n%s", certs[0].Subject.CommonName)))
+	}) + "/app"
+}
+
+// TestNewIdentity tests generating identities for each key algorithm.
+func TestNewIdentity(t *testing.T) {
+	for _, algorithm := range []gemini.KeyAlgorithm{gemini.KEY_ALGORITHM_ECDSA, gemini.KEY_ALGORITHM_ED25519} {
+		identity, err := gemini.NewIdentity("alice", algorithm)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "alice", identity.Certificate.Leaf.Subject.CommonName)
+			assert.NotEmpty(t, identity.Fingerprint())
+		}
+	}
+}
+
+// TestNewIdentityInvalidName tests rejecting names that cannot be saved.
+func TestNewIdentityInvalidName(t *testing.T) {
+	for _, name := range []string{"", "  ", "..", "a/b"} {
+		_, err := gemini.NewIdentity(name, gemini.KEY_ALGORITHM_ECDSA)
+		assert.NotNil(t, err)
+	}
+}
+
+// TestIdentitiesLookup tests picking the identity with the longest matching scope.
+func TestIdentitiesLookup(t *testing.T) {
+	identities := gemini.NewMemoryIdentities()
+	alice, _ := gemini.NewIdentity("alice", gemini.KEY_ALGORITHM_ECDSA)
+	bob, _ := gemini.NewIdentity("bob", gemini.KEY_ALGORITHM_ED25519)
+	identities.Add(alice)
+	identities.Add(bob)
+	assert.Nil(t, identities.Bind("alice", "gemini://example.com/"))
+	assert.Nil(t, identities.Bind("bob", "gemini://example.com/app"))
+
+	identity, ok := identities.Lookup("gemini://example.com/app/page?q")
+	if assert.True(t, ok) {
+		assert.Equal(t, "bob", identity.Name)
+	}
+	identity, ok = identities.Lookup("gemini://example.com/apple")
+	if assert.True(t, ok) {
+		assert.Equal(t, "alice", identity.Name)
+	}
+	_, ok = identities.Lookup("gemini://other.com/")
+	assert.False(t, ok)
+	assert.NotNil(t, identities.Bind("carol", "gemini://example.com/"))
+}
+
+// TestFileIdentitiesPersist tests reloading identities and scopes from disk.
+func TestFileIdentitiesPersist(t *testing.T) {
+	dir := t.TempDir()
+	identities, err := gemini.OpenFileIdentities(dir)
+	if !assert.Nil(t, err) {
+		return
+	}
+	alice, _ := gemini.NewIdentity("alice smith", gemini.KEY_ALGORITHM_ED25519)
+	assert.Nil(t, identities.Add(alice))
+	assert.Nil(t, identities.Bind("alice smith", "gemini://example.com/"))
+
+	reloaded, err := gemini.OpenFileIdentities(dir)
+	if assert.Nil(t, err) {
+		identity, ok := reloaded.Lookup("gemini://example.com/foo")
+		if assert.True(t, ok) {
+			assert.Equal(t, "alice smith", identity.Name)
+			assert.Equal(t, alice.Fingerprint(), identity.Fingerprint())
+		}
+	}
+}
+
+// TestRequestPresentsIdentity tests presenting the identity bound to the url.
+func TestRequestPresentsIdentity(t *testing.T) {
+	rawurl := serveClientCertificate(t)
+	client := gemini.MakeClient()
+	alice, _ := gemini.NewIdentity("alice", gemini.KEY_ALGORITHM_ECDSA)
+	client.Identities.Add(alice)
+	client.Identities.Bind("alice", rawurl)
+
+	resp, err := client.NavigatePage(rawurl)
+	if assert.Nil(t, err) {
+		assert.Equal(t, gemini.STATUS_SUCCESS, resp.Response.Header.Status)
+		data, _ := resp.Response.ReadAll(0)
+		assert.Equal(t, "alice", string(data))
+		assert.Equal(t, "alice", resp.Identity.Name)
+	}
+}
+
+// TestRequestWithoutIdentity tests not presenting a certificate outside any scope.
+func TestRequestWithoutIdentity(t *testing.T) {
+	rawurl := serveClientCertificate(t)
+	client := gemini.MakeClient()
+	_, err := client.NavigatePage(rawurl)
+	var statusErr *gemini.StatusError
+	if assert.True(t, errors.As(err, &statusErr)) {
+		assert.Equal(t, gemini.STATUS_CLIENT_CERTIFICATE_REQUIRED, statusErr.Status)
+	}
+}
@@ -0,0 +1,81 @@
+package gemini
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// MediaType is a MIME type parsed from the meta of a success response.
+type MediaType struct {
+	Type    string
+	Subtype string
+	// Params holds parameters with lowercase names, e.g. charset and lang.
+	Params map[string]string
+}
+
+// ParseMediaType parses the meta of a success response.
+// An empty meta is text/gemini as per the Gemini spec.
+func ParseMediaType(meta string) (MediaType, error) {
+	if len(strings.TrimSpace(meta)) == 0 {
+		meta = "text/gemini; charset=utf-8"
+	}
+	mediatype, params, err := mime.ParseMediaType(meta)
+	if err != nil && !errors.Is(err, mime.ErrInvalidMediaParameter) {
+		return MediaType{}, err
+	}
+	typ, subtype, ok := strings.Cut(mediatype, "/")
+	if !ok {
+		return MediaType{}, errors.New("mime: expected slash after first token")
+	}
+	if params == nil {
+		params = make(map[string]string)
+	}
+	return MediaType{
+		Type:    typ,
+		Subtype: subtype,
+		Params:  params,
+	}, nil
+}
+
+// String gets the type and subtype without parameters, e.g. text/gemini.
+func (m MediaType) String() string {
+	if len(m.Type) == 0 {
+		return ""
+	}
+	return m.Type + "/" + m.Subtype
+}
+
+// Charset gets the lowercase charset parameter, defaulting to utf-8 for text types.
+func (m MediaType) Charset() string {
+	if charset, ok := m.Params["charset"]; ok {
+		return strings.ToLower(charset)
+	}
+	if m.Type == "text" {
+		return "utf-8"
+	}
+	return ""
+}
+
+// Lang gets the lang parameter.
+func (m MediaType) Lang() string {
+	return m.Params["lang"]
+}
+
+// DecodeCharset wraps r to decode text in charset to UTF-8.
+// Charsets are named as in the WHATWG Encoding Standard, e.g. iso-8859-1 or shift_jis.
+func DecodeCharset(r io.Reader, charset string) (io.Reader, error) {
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	if len(charset) == 0 || charset == "utf-8" || charset == "utf8" || charset == "us-ascii" {
+		return r, nil
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, err
+	}
+	return transform.NewReader(r, enc.NewDecoder()), nil
+}
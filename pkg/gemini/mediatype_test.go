@@ -0,0 +1,82 @@
+package gemini_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jasmaa/hikawa/pkg/gemini"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseMediaTypeParams tests parsing charset and lang parameters.
+func TestParseMediaTypeParams(t *testing.T) {
+	mediaType, err := gemini.ParseMediaType("text/gemini; charset=UTF-8; lang=en-US")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "text", mediaType.Type)
+		assert.Equal(t, "gemini", mediaType.Subtype)
+		assert.Equal(t, "text/gemini", mediaType.String())
+		assert.Equal(t, "utf-8", mediaType.Charset())
+		assert.Equal(t, "en-US", mediaType.Lang())
+	}
+}
+
+// TestParseMediaTypeCase tests lowercasing the type and parameter names.
+func TestParseMediaTypeCase(t *testing.T) {
+	mediaType, err := gemini.ParseMediaType("Text/Gemini;Charset=ISO-8859-1")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "text/gemini", mediaType.String())
+		assert.Equal(t, "iso-8859-1", mediaType.Charset())
+	}
+}
+
+// TestParseMediaTypeEmpty tests defaulting empty meta to text/gemini.
+func TestParseMediaTypeEmpty(t *testing.T) {
+	mediaType, err := gemini.ParseMediaType("")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "text/gemini", mediaType.String())
+		assert.Equal(t, "utf-8", mediaType.Charset())
+	}
+}
+
+// TestParseMediaTypeDefaultCharset tests charset defaults by type.
+func TestParseMediaTypeDefaultCharset(t *testing.T) {
+	mediaType, _ := gemini.ParseMediaType("text/plain")
+	assert.Equal(t, "utf-8", mediaType.Charset())
+	mediaType, _ = gemini.ParseMediaType("image/png")
+	assert.Equal(t, "", mediaType.Charset())
+}
+
+// TestParseMediaTypeInvalid tests erroring on a meta without a subtype.
+func TestParseMediaTypeInvalid(t *testing.T) {
+	_, err := gemini.ParseMediaType("text")
+	assert.NotNil(t, err)
+}
+
+// TestDecodeCharset tests decoding legacy charsets to UTF-8.
+func TestDecodeCharset(t *testing.T) {
+	tests := []struct {
+		charset string
+		raw     string
+		text    string
+	}{
+		{"utf-8", "caf\xc3\xa9", "café"},
+		{"iso-8859-1", "caf\xe9", "café"},
+		{"windows-1252", "\x93quoted\x94", "“quoted”"},
+		{"shift_jis", "\x82\xb1\x82\xf1\x82\xc9\x82\xbf\x82\xcd", "こんにちは"},
+	}
+	for _, test := range tests {
+		reader, err := gemini.DecodeCharset(strings.NewReader(test.raw), test.charset)
+		if assert.Nil(t, err, test.charset) {
+			data, err := io.ReadAll(reader)
+			assert.Nil(t, err)
+			assert.Equal(t, test.text, string(data), test.charset)
+		}
+	}
+}
+
+// TestDecodeCharsetUnknown tests erroring on an unknown charset.
+func TestDecodeCharsetUnknown(t *testing.T) {
+	_, err := gemini.DecodeCharset(strings.NewReader(""), "not-a-charset")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,177 @@
+package gemini
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// MAX_REQUEST_LENGTH is the maximum length of a request url in bytes, not counting CRLF.
+const MAX_REQUEST_LENGTH = 1024
+
+// ErrRequestTooLong is returned when a request url is longer than MAX_REQUEST_LENGTH.
+var ErrRequestTooLong = errors.New("request url is longer than 1024 bytes")
+
+// ErrUserinfo is returned by Normalize for urls with userinfo, which Gemini forbids.
+var ErrUserinfo = errors.New("url must not contain userinfo")
+
+// defaultPorts are the ports dropped from urls by Normalize.
+var defaultPorts = map[string]string{
+	"gemini": DEFAULT_PORT,
+	"titan":  DEFAULT_PORT,
+	"gopher": "70",
+	"http":   "80",
+	"https":  "443",
+}
+
+// Normalize puts an absolute url in a standard form so equal urls compare equal.
+// The scheme and host are lowercased, the default port and fragment are dropped,
+// dot segments are resolved, an empty path becomes "/" and percent-encoding is
+// made consistent. Urls with userinfo are rejected with ErrUserinfo.
+func Normalize(rawurl string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawurl))
+	if err != nil {
+		return "", err
+	}
+	if len(u.Scheme) == 0 {
+		return "", fmt.Errorf("url is not absolute: %s", rawurl)
+	}
+	if u.User != nil {
+		return "", ErrUserinfo
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if len(u.Opaque) > 0 {
+		return scheme + ":" + normalizeEscapes(u.Opaque, isPathChar), nil
+	}
+
+	var b strings.Builder
+	b.WriteString(scheme)
+	b.WriteString("://")
+	b.WriteString(normalizeHost(u, scheme))
+
+	escapedPath := removeDotSegments(normalizeEscapes(u.EscapedPath(), isPathChar))
+	if len(escapedPath) == 0 {
+		escapedPath = "/"
+	}
+	b.WriteString(escapedPath)
+
+	if u.ForceQuery || len(u.RawQuery) > 0 {
+		b.WriteByte('?')
+		b.WriteString(normalizeEscapes(u.RawQuery, isQueryChar))
+	}
+	return b.String(), nil
+}
+
+// EscapeQuery escapes user input to send as a Gemini query. Spaces become %20.
+func EscapeQuery(input string) string {
+	return strings.ReplaceAll(url.QueryEscape(input), "+", "%20")
+}
+
+// normalizeHost lowercases the host of u and drops the default port for scheme.
+func normalizeHost(u *url.URL, scheme string) string {
+	host := u.Hostname()
+	// IPv6 zone identifiers are case-sensitive and have their % escaped (RFC 6874)
+	zone := ""
+	if i := strings.IndexByte(host, '%'); i >= 0 && strings.Contains(host, ":") {
+		host, zone = host[:i], "%25"+normalizeEscapes(host[i+1:], isUnreserved)
+	}
+	host = strings.ToLower(host) + zone
+	port := u.Port()
+	if port == defaultPorts[scheme] {
+		port = ""
+	}
+	if len(port) > 0 {
+		return net.JoinHostPort(host, port)
+	}
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// normalizeEscapes percent-encodes bytes that isAllowed rejects, uppercases
+// existing escapes and decodes escaped unreserved characters.
+func normalizeEscapes(s string, isAllowed func(c byte) bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			decoded := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(decoded) {
+				b.WriteByte(decoded)
+			} else {
+				b.WriteByte('%')
+				b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+			}
+			i += 2
+			continue
+		}
+		if c != '%' && isAllowed(c) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// removeDotSegments resolves "." and ".." segments as in RFC 3986 section 5.2.4.
+func removeDotSegments(p string) string {
+	var out []string
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, segment)
+		}
+	}
+	result := strings.Join(out, "/")
+	if strings.HasPrefix(p, "/") && !strings.HasPrefix(result, "/") {
+		result = "/" + result
+	}
+	return result
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// isPathChar checks for characters allowed unescaped in a path.
+func isPathChar(c byte) bool {
+	return isUnreserved(c) || strings.IndexByte("!$&'()*+,;=:@/", c) >= 0
+}
+
+// isQueryChar checks for characters allowed unescaped in a query.
+func isQueryChar(c byte) bool {
+	return isPathChar(c) || c == '?'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -2,33 +2,26 @@ package gemini
 
 import (
 	"net/url"
-	"path"
+	"strings"
 )
 
-// NextUrl constructs next URL for link navigation
+// NextUrl constructs next URL for link navigation.
+// newUrl is resolved against currentUrl as a reference per RFC 3986 section 5.2,
+// so protocol-relative, query-only and dot-segment links work and trailing
+// slashes are kept. A relative link from a page with an empty path resolves
+// against "/", which Gemini treats as the same page.
 func NextUrl(currentUrl string, newUrl string) (string, error) {
 	u, err := url.Parse(currentUrl)
 	if err != nil {
 		return "", err
 	}
-	newU, err := url.Parse(newUrl)
+	ref, err := url.Parse(strings.TrimSpace(newUrl))
 	if err != nil {
 		return "", err
 	}
-	if len(newU.Scheme) == 0 {
-		// Same host
-		if path.IsAbs(newU.Path) {
-			// Absolute url
-			u.Path = newU.Path
-		} else {
-			// Relative url
-			dir, _ := path.Split(u.Path)
-			u.Path = path.Join(dir, newU.Path)
-		}
-		u.RawQuery = newU.RawQuery
-		return u.String(), nil
-	} else {
-		// New host
-		return newUrl, nil
+	next := u.ResolveReference(ref)
+	if len(ref.Scheme) == 0 && len(ref.Host) == 0 && len(next.Host) > 0 && len(next.Path) == 0 {
+		next.Path = "/"
 	}
+	return next.String(), nil
 }
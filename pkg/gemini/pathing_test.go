@@ -38,3 +38,127 @@ func TestNextUrlFile(t *testing.T) {
 		assert.Equal(t, "gemini://foo.com/1/2/bar/otherContent.gmi", targetUrl)
 	}
 }
+
+// TestNextUrlRFC3986 tests the reference resolution examples from RFC 3986 section 5.4.
+func TestNextUrlRFC3986(t *testing.T) {
+	base := "http://a/b/c/d;p?q"
+	tests := []struct {
+		ref    string
+		target string
+	}{
+		// Normal examples, section 5.4.1
+		{"g:h", "g:h"},
+		{"g", "http://a/b/c/g"},
+		{"./g", "http://a/b/c/g"},
+		{"g/", "http://a/b/c/g/"},
+		{"/g", "http://a/g"},
+		{"//g", "http://g"},
+		{"?y", "http://a/b/c/d;p?y"},
+		{"g?y", "http://a/b/c/g?y"},
+		{"#s", "http://a/b/c/d;p?q#s"},
+		{"g#s", "http://a/b/c/g#s"},
+		{"g?y#s", "http://a/b/c/g?y#s"},
+		{";x", "http://a/b/c/;x"},
+		{"g;x", "http://a/b/c/g;x"},
+		{"g;x?y#s", "http://a/b/c/g;x?y#s"},
+		{"", "http://a/b/c/d;p?q"},
+		{".", "http://a/b/c/"},
+		{"./", "http://a/b/c/"},
+		{"..", "http://a/b/"},
+		{"../", "http://a/b/"},
+		{"../g", "http://a/b/g"},
+		{"../..", "http://a/"},
+		{"../../", "http://a/"},
+		{"../../g", "http://a/g"},
+		// Abnormal examples, section 5.4.2
+		{"../../../g", "http://a/g"},
+		{"../../../../g", "http://a/g"},
+		{"/./g", "http://a/g"},
+		{"/../g", "http://a/g"},
+		{"g.", "http://a/b/c/g."},
+		{".g", "http://a/b/c/.g"},
+		{"g..", "http://a/b/c/g.."},
+		{"..g", "http://a/b/c/..g"},
+		{"./../g", "http://a/b/g"},
+		{"./g/.", "http://a/b/c/g/"},
+		{"g/./h", "http://a/b/c/g/h"},
+		{"g/../h", "http://a/b/c/h"},
+		{"g;x=1/./y", "http://a/b/c/g;x=1/y"},
+		{"g;x=1/../y", "http://a/b/c/y"},
+		{"g?y/./x", "http://a/b/c/g?y/./x"},
+		{"g?y/../x", "http://a/b/c/g?y/../x"},
+		{"g#s/./x", "http://a/b/c/g#s/./x"},
+		{"g#s/../x", "http://a/b/c/g#s/../x"},
+		{"http:g", "http:g"},
+	}
+	for _, test := range tests {
+		targetUrl, err := gemini.NextUrl(base, test.ref)
+		if assert.Nil(t, err, test.ref) {
+			assert.Equal(t, test.target, targetUrl, test.ref)
+		}
+	}
+}
+
+// TestNextUrlProtocolRelative tests link navigation to a new host with the same scheme
+func TestNextUrlProtocolRelative(t *testing.T) {
+	targetUrl, err := gemini.NextUrl("gemini://foo.com/1/2/", "//bar.com/x")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "gemini://bar.com/x", targetUrl)
+	}
+}
+
+// TestNextUrlQueryOnly tests link navigation to a query on the same page
+func TestNextUrlQueryOnly(t *testing.T) {
+	targetUrl, err := gemini.NextUrl("gemini://foo.com/search?old", "?new")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "gemini://foo.com/search?new", targetUrl)
+	}
+}
+
+// TestNextUrlKeepsQuery tests keeping the query of a relative link
+func TestNextUrlKeepsQuery(t *testing.T) {
+	targetUrl, err := gemini.NextUrl("gemini://foo.com/1/2/", "../bar?x=1")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "gemini://foo.com/1/bar?x=1", targetUrl)
+	}
+}
+
+// TestNextUrlTrailingSlash tests keeping the trailing slash of a directory link
+func TestNextUrlTrailingSlash(t *testing.T) {
+	targetUrl, err := gemini.NextUrl("gemini://foo.com/1/content.gmi", "dir/")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "gemini://foo.com/1/dir/", targetUrl)
+	}
+}
+
+// TestNextUrlEmptyPath tests link navigation from a page without a path
+func TestNextUrlEmptyPath(t *testing.T) {
+	targetUrl, err := gemini.NextUrl("gemini://foo.com", "bar")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "gemini://foo.com/bar", targetUrl)
+	}
+	targetUrl, err = gemini.NextUrl("gemini://foo.com", "?q")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "gemini://foo.com/?q", targetUrl)
+	}
+}
+
+// TestNextUrlFileScheme tests link navigation between local files
+func TestNextUrlFileScheme(t *testing.T) {
+	tests := []struct {
+		current string
+		link    string
+		target  string
+	}{
+		{"file:///home/me/capsule/", "posts/first.gmi", "file:///home/me/capsule/posts/first.gmi"},
+		{"file:///home/me/capsule/posts/first.gmi", "../index.gmi", "file:///home/me/capsule/index.gmi"},
+		{"file:///home/me/capsule/index.gmi", "/etc/", "file:///etc/"},
+		{"file:///C:/capsule/index.gmi", "about.gmi", "file:///C:/capsule/about.gmi"},
+	}
+	for _, test := range tests {
+		targetUrl, err := gemini.NextUrl(test.current, test.link)
+		if assert.Nil(t, err, test.link) {
+			assert.Equal(t, test.target, targetUrl, test.link)
+		}
+	}
+}
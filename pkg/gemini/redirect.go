@@ -0,0 +1,120 @@
+package gemini
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Redirect is a redirect from one url to another.
+type Redirect struct {
+	From   string
+	To     string
+	Status Status
+	// CrossHost is set when the redirect changes host or port.
+	CrossHost bool
+	// CrossScheme is set when the redirect changes scheme.
+	CrossScheme bool
+	// Downgrade is set when the redirect goes from an encrypted scheme to an unencrypted one.
+	Downgrade bool
+}
+
+// IsPermanent checks if the redirect is a 31 permanent redirect.
+func (r Redirect) IsPermanent() bool {
+	return r.Status == STATUS_REDIRECT_PERMANENT
+}
+
+// RedirectAction is what a RedirectPolicy decides to do with a redirect.
+type RedirectAction int
+
+const (
+	// REDIRECT_FOLLOW follows the redirect.
+	REDIRECT_FOLLOW RedirectAction = iota
+	// REDIRECT_DENY stops with an error wrapping ErrRedirectDenied.
+	REDIRECT_DENY
+	// REDIRECT_ASK stops and returns the redirect in ClientResponse.PendingRedirect.
+	REDIRECT_ASK
+)
+
+// RedirectPolicy decides whether the client follows a redirect.
+type RedirectPolicy func(redirect Redirect) RedirectAction
+
+// DefaultRedirectPolicy follows redirects on the same host and scheme and
+// asks about the rest.
+func DefaultRedirectPolicy(redirect Redirect) RedirectAction {
+	if redirect.CrossHost || redirect.CrossScheme || redirect.Downgrade {
+		return REDIRECT_ASK
+	}
+	return REDIRECT_FOLLOW
+}
+
+// ErrRedirectDenied is returned when a RedirectPolicy denies a redirect.
+var ErrRedirectDenied = errors.New("redirect denied")
+
+// encryptedSchemes are schemes that are always sent over TLS.
+var encryptedSchemes = map[string]bool{
+	"gemini": true,
+	"titan":  true,
+	"https":  true,
+}
+
+// resolveRedirect resolves the meta of a 3X response against the url that returned it.
+func resolveRedirect(from string, status Status, meta string) (Redirect, error) {
+	if len(strings.TrimSpace(meta)) == 0 {
+		return Redirect{}, errors.New("redirect has no url")
+	}
+	fromUrl, err := url.Parse(from)
+	if err != nil {
+		return Redirect{}, err
+	}
+	ref, err := url.Parse(strings.TrimSpace(meta))
+	if err != nil {
+		return Redirect{}, fmt.Errorf("invalid redirect url: %w", err)
+	}
+	to, err := Normalize(fromUrl.ResolveReference(ref).String())
+	if err != nil {
+		return Redirect{}, fmt.Errorf("invalid redirect url: %w", err)
+	}
+	toUrl, err := url.Parse(to)
+	if err != nil {
+		return Redirect{}, err
+	}
+	return Redirect{
+		From:        from,
+		To:          to,
+		Status:      status,
+		CrossHost:   !strings.EqualFold(fromUrl.Host, toUrl.Host),
+		CrossScheme: fromUrl.Scheme != toUrl.Scheme,
+		Downgrade:   encryptedSchemes[fromUrl.Scheme] && !encryptedSchemes[toUrl.Scheme],
+	}, nil
+}
+
+// RedirectCache records permanent redirects so later requests skip the hop.
+type RedirectCache struct {
+	mu        sync.RWMutex
+	redirects map[string]string
+}
+
+// NewRedirectCache creates an empty RedirectCache.
+func NewRedirectCache() *RedirectCache {
+	return &RedirectCache{
+		redirects: make(map[string]string),
+	}
+}
+
+// Lookup gets the url that rawurl permanently redirects to.
+func (r *RedirectCache) Lookup(rawurl string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	to, ok := r.redirects[rawurl]
+	return to, ok
+}
+
+// Record records a permanent redirect.
+func (r *RedirectCache) Record(redirect Redirect) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.redirects[redirect.From] = redirect.To
+}
@@ -0,0 +1,130 @@
+package gemini_test
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jasmaa/hikawa/pkg/gemini"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRedirectRelative tests resolving a relative redirect against the request url.
+func TestRedirectRelative(t *testing.T) {
+	base := serveFunc(t, func(requestUrl string) string {
+		if strings.HasSuffix(requestUrl, "/a/b/c") {
+			return "30 ../foo?q\r\n"
+		}
+		return "20 text/gemini\r\n" + requestUrl
+	})
+	client := gemini.MakeClient()
+	resp, err := client.NavigatePage(base + "/a/b/c")
+	if assert.Nil(t, err) {
+		assert.Equal(t, base+"/a/foo?q", resp.Url)
+		data, _ := resp.Response.ReadAll(0)
+		assert.Equal(t, base+"/a/foo?q", string(data))
+	}
+}
+
+// TestRedirectChain tests exposing every redirect followed.
+func TestRedirectChain(t *testing.T) {
+	base := serveFunc(t, func(requestUrl string) string {
+		switch {
+		case strings.HasSuffix(requestUrl, "/1"):
+			return "30 /2\r\n"
+		case strings.HasSuffix(requestUrl, "/2"):
+			return "31 /3\r\n"
+		default:
+			return "20 text/gemini\r\nend"
+		}
+	})
+	client := gemini.MakeClient()
+	resp, err := client.NavigatePage(base + "/1")
+	if assert.Nil(t, err) {
+		defer resp.Response.Body.Close()
+		assert.Equal(t, base+"/3", resp.Url)
+		if assert.Len(t, resp.Redirects, 2) {
+			assert.Equal(t, base+"/1", resp.Redirects[0].From)
+			assert.Equal(t, base+"/2", resp.Redirects[0].To)
+			assert.False(t, resp.Redirects[0].IsPermanent())
+			assert.Equal(t, base+"/3", resp.Redirects[1].To)
+			assert.True(t, resp.Redirects[1].IsPermanent())
+		}
+	}
+}
+
+// TestRedirectPermanentRecorded tests skipping a recorded permanent redirect.
+func TestRedirectPermanentRecorded(t *testing.T) {
+	var oldHits int32
+	base := serveFunc(t, func(requestUrl string) string {
+		if strings.HasSuffix(requestUrl, "/old") {
+			atomic.AddInt32(&oldHits, 1)
+			return "31 /new\r\n"
+		}
+		return "20 text/gemini\r\nnew"
+	})
+	client := gemini.MakeClient()
+	for i := 0; i < 2; i++ {
+		resp, err := client.NavigatePage(base + "/old")
+		if assert.Nil(t, err) {
+			resp.Response.Body.Close()
+			assert.Equal(t, base+"/new", resp.Url)
+		}
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&oldHits))
+}
+
+// TestRedirectCrossHostAsks tests stopping at a cross-host redirect with the default policy.
+func TestRedirectCrossHostAsks(t *testing.T) {
+	base := serveFunc(t, func(requestUrl string) string {
+		return "30 gemini://other.example/page\r\n"
+	})
+	client := gemini.MakeClient()
+	resp, err := client.NavigatePage(base + "/")
+	if assert.Nil(t, err) {
+		assert.Equal(t, base+"/", resp.Url)
+		if assert.NotNil(t, resp.PendingRedirect) {
+			assert.Equal(t, "gemini://other.example/page", resp.PendingRedirect.To)
+			assert.True(t, resp.PendingRedirect.CrossHost)
+			assert.False(t, resp.PendingRedirect.CrossScheme)
+		}
+	}
+}
+
+// TestRedirectDowngrade tests flagging a redirect to an unencrypted scheme.
+func TestRedirectDowngrade(t *testing.T) {
+	base := serveFunc(t, func(requestUrl string) string {
+		return "30 http://other.example/\r\n"
+	})
+	var seen gemini.Redirect
+	client := gemini.MakeClient()
+	client.RedirectPolicy = func(redirect gemini.Redirect) gemini.RedirectAction {
+		seen = redirect
+		return gemini.REDIRECT_DENY
+	}
+	_, err := client.NavigatePage(base + "/")
+	assert.True(t, errors.Is(err, gemini.ErrRedirectDenied))
+	assert.True(t, seen.CrossScheme)
+	assert.True(t, seen.Downgrade)
+}
+
+// TestRedirectLimit tests erroring on a redirect loop.
+func TestRedirectLimit(t *testing.T) {
+	base := serveFunc(t, func(requestUrl string) string {
+		return "30 /loop\r\n"
+	})
+	client := gemini.MakeClient()
+	_, err := client.NavigatePage(base + "/loop")
+	assert.NotNil(t, err)
+}
+
+// TestRedirectEmptyMeta tests erroring on a redirect without a url.
+func TestRedirectEmptyMeta(t *testing.T) {
+	base := serveFunc(t, func(requestUrl string) string {
+		return "30 \r\n"
+	})
+	client := gemini.MakeClient()
+	_, err := client.NavigatePage(base + "/")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,112 @@
+package gemini
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy decides which failures are retried and how long to wait between attempts.
+type RetryPolicy struct {
+	// Statuses are the 4X statuses that are retried.
+	Statuses map[Status]bool
+	// RetryNetworkErrors retries requests that fail to connect or are cut off.
+	RetryNetworkErrors bool
+	// BaseDelay is the wait before the first retry. It doubles on every retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff between retries.
+	MaxDelay time.Duration
+	// Jitter is the fraction of each backoff that is randomized, from 0 to 1.
+	Jitter float64
+	// MaxSlowDown caps the wait requested by 44 SLOW DOWN.
+	MaxSlowDown time.Duration
+}
+
+// DefaultRetryPolicy makes the default retry policy.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Statuses: map[Status]bool{
+			STATUS_TEMPORARY_FAILURE:  true,
+			STATUS_SERVER_UNAVAILABLE: true,
+			STATUS_SLOW_DOWN:          true,
+		},
+		RetryNetworkErrors: true,
+		BaseDelay:          500 * time.Millisecond,
+		MaxDelay:           5 * time.Second,
+		Jitter:             0.2,
+		MaxSlowDown:        30 * time.Second,
+	}
+}
+
+// RetryEvent describes a retry about to happen.
+type RetryEvent struct {
+	Url string
+	// Attempt is the number of the upcoming retry, starting at 1.
+	Attempt int
+	// Delay is the wait before the retry.
+	Delay time.Duration
+	// Status is the status that caused the retry, or 0 for a network error.
+	Status Status
+	// Err is the network error that caused the retry, if any.
+	Err error
+}
+
+// ShouldRetryStatus checks if a response status is retried.
+func (p RetryPolicy) ShouldRetryStatus(status Status) bool {
+	return p.Statuses[status]
+}
+
+// ShouldRetryError checks if a request error is retried.
+// Cancellations, timeouts, DNS misses and TLS alerts are not retried.
+func (p RetryPolicy) ShouldRetryError(err error) bool {
+	if !p.RetryNetworkErrors {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return !opErr.Timeout() && opErr.Op != "remote error"
+	}
+	return false
+}
+
+// Delay gets the wait before a retry. attempt starts at 1. A 44 SLOW DOWN
+// response waits the number of seconds in its meta, capped at MaxSlowDown.
+func (p RetryPolicy) Delay(attempt int, resp *Response) time.Duration {
+	if resp != nil && resp.Header.Status == STATUS_SLOW_DOWN {
+		seconds, err := strconv.Atoi(strings.TrimSpace(resp.Header.Meta))
+		if err == nil && seconds >= 0 {
+			delay := time.Duration(seconds) * time.Second
+			if p.MaxSlowDown > 0 && delay > p.MaxSlowDown {
+				delay = p.MaxSlowDown
+			}
+			return delay
+		}
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		delay -= time.Duration(rand.Float64() * p.Jitter * float64(delay))
+	}
+	return delay
+}
@@ -0,0 +1,140 @@
+package gemini_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jasmaa/hikawa/pkg/gemini"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRetryDelayBackoff tests doubling the delay up to the maximum.
+func TestRetryDelayBackoff(t *testing.T) {
+	policy := gemini.DefaultRetryPolicy()
+	policy.BaseDelay = time.Second
+	policy.MaxDelay = 5 * time.Second
+	policy.Jitter = 0
+	assert.Equal(t, time.Second, policy.Delay(1, nil))
+	assert.Equal(t, 2*time.Second, policy.Delay(2, nil))
+	assert.Equal(t, 4*time.Second, policy.Delay(3, nil))
+	assert.Equal(t, 5*time.Second, policy.Delay(4, nil))
+	assert.Equal(t, 5*time.Second, policy.Delay(100, nil))
+}
+
+// TestRetryDelayJitter tests keeping jittered delays within bounds.
+func TestRetryDelayJitter(t *testing.T) {
+	policy := gemini.DefaultRetryPolicy()
+	policy.BaseDelay = time.Second
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := policy.Delay(1, nil)
+		assert.LessOrEqual(t, delay, time.Second)
+		assert.GreaterOrEqual(t, delay, 500*time.Millisecond)
+	}
+}
+
+// TestRetryDelaySlowDown tests honoring the wait from 44 SLOW DOWN.
+func TestRetryDelaySlowDown(t *testing.T) {
+	policy := gemini.DefaultRetryPolicy()
+	policy.MaxSlowDown = 10 * time.Second
+	slowDown := func(meta string) *gemini.Response {
+		return &gemini.Response{Header: gemini.ResponseHeader{Status: gemini.STATUS_SLOW_DOWN, Meta: meta}}
+	}
+	assert.Equal(t, 5*time.Second, policy.Delay(1, slowDown("5")))
+	assert.Equal(t, 10*time.Second, policy.Delay(1, slowDown("3600")))
+	policy.Jitter = 0
+	assert.Equal(t, policy.BaseDelay, policy.Delay(1, slowDown("soon")))
+}
+
+// TestRetryErrors tests which request errors are retried.
+func TestRetryErrors(t *testing.T) {
+	policy := gemini.DefaultRetryPolicy()
+	assert.True(t, policy.ShouldRetryError(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.True(t, policy.ShouldRetryError(io.ErrUnexpectedEOF))
+	assert.False(t, policy.ShouldRetryError(&net.OpError{Op: "remote error", Err: errors.New("bad certificate")}))
+	assert.False(t, policy.ShouldRetryError(&net.OpError{Op: "dial", Err: &net.DNSError{IsNotFound: true}}))
+	assert.False(t, policy.ShouldRetryError(context.Canceled))
+	assert.False(t, policy.ShouldRetryError(&gemini.UnknownHostError{}))
+	policy.RetryNetworkErrors = false
+	assert.False(t, policy.ShouldRetryError(io.ErrUnexpectedEOF))
+}
+
+// TestNavigatePageRetriesSlowDown tests retrying 44 SLOW DOWN with events.
+func TestNavigatePageRetriesSlowDown(t *testing.T) {
+	var hits int32
+	base := serveFunc(t, func(requestUrl string) string {
+		if atomic.AddInt32(&hits, 1) <= 2 {
+			return "44 0\r\n"
+		}
+		return "20 text/gemini\r\nok"
+	})
+	var events []gemini.RetryEvent
+	client := gemini.MakeClient()
+	client.OnRetry = func(event gemini.RetryEvent) {
+		events = append(events, event)
+	}
+	resp, err := client.NavigatePage(base + "/")
+	if assert.Nil(t, err) {
+		data, _ := resp.Response.ReadAll(0)
+		assert.Equal(t, "ok", string(data))
+	}
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, 1, events[0].Attempt)
+		assert.Equal(t, 2, events[1].Attempt)
+		assert.Equal(t, gemini.STATUS_SLOW_DOWN, events[1].Status)
+		assert.Equal(t, time.Duration(0), events[1].Delay)
+	}
+}
+
+// TestNavigatePageRetriesExhausted tests returning the last failure after the final retry.
+func TestNavigatePageRetriesExhausted(t *testing.T) {
+	var hits int32
+	base := serveFunc(t, func(requestUrl string) string {
+		atomic.AddInt32(&hits, 1)
+		return "41 down\r\n"
+	})
+	client := gemini.MakeClient()
+	client.RetryPolicy.BaseDelay = time.Millisecond
+	_, err := client.NavigatePage(base + "/")
+	var statusErr *gemini.StatusError
+	if assert.True(t, errors.As(err, &statusErr)) {
+		assert.Equal(t, gemini.STATUS_SERVER_UNAVAILABLE, statusErr.Status)
+	}
+	assert.Equal(t, int32(client.MaxRetries+1), atomic.LoadInt32(&hits))
+}
+
+// TestNavigatePageNoRetry tests returning statuses outside the policy immediately.
+func TestNavigatePageNoRetry(t *testing.T) {
+	var hits int32
+	base := serveFunc(t, func(requestUrl string) string {
+		atomic.AddInt32(&hits, 1)
+		return "42 script failed\r\n"
+	})
+	client := gemini.MakeClient()
+	_, err := client.NavigatePage(base + "/")
+	var statusErr *gemini.StatusError
+	if assert.True(t, errors.As(err, &statusErr)) {
+		assert.Equal(t, gemini.STATUS_CGI_ERROR, statusErr.Status)
+		assert.Equal(t, "script failed", statusErr.Meta)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+// TestNavigatePageRetryCancel tests stopping a retry wait when cancelled.
+func TestNavigatePageRetryCancel(t *testing.T) {
+	base := serveFunc(t, func(requestUrl string) string {
+		return "44 60\r\n"
+	})
+	client := gemini.MakeClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	client.OnRetry = func(event gemini.RetryEvent) {
+		cancel()
+	}
+	_, err := client.NavigatePageContext(ctx, base+"/")
+	assert.ErrorIs(t, err, context.Canceled)
+}
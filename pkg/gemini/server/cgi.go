@@ -0,0 +1,289 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jasmaa/hikawa/pkg/gemini"
+)
+
+// DEFAULT_CGI_TIMEOUT limits scripts and SCGI backends when no timeout is given.
+const DEFAULT_CGI_TIMEOUT = 10 * time.Second
+
+// CGIHandler serves requests by running a script. The script gets the request
+// in the Gemini CGI environment and writes a Gemini response to stdout. A
+// script that fails or times out before writing a valid header gets 42 CGI ERROR.
+type CGIHandler struct {
+	// Path is the path of the script.
+	Path string
+	// Root is the url path the script is mounted at. The rest of the path is PATH_INFO.
+	Root string
+	// Dir is the working directory of the script. If empty, the directory of Path is used.
+	Dir string
+	// Env holds extra environment variables as "KEY=value".
+	Env []string
+	// Args holds extra arguments for the script.
+	Args []string
+	// Timeout limits how long the script runs. If zero, DEFAULT_CGI_TIMEOUT is used.
+	Timeout time.Duration
+	// ErrorLog logs script failures and stderr. If nil, the log package's logger is used.
+	ErrorLog *log.Logger
+}
+
+func (h *CGIHandler) ServeGemini(w ResponseWriter, r *Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), cgiTimeout(h.Timeout))
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.Path, h.Args...)
+	cmd.Dir = h.Dir
+	if len(cmd.Dir) == 0 {
+		cmd.Dir = filepath.Dir(h.Path)
+	}
+	cmd.Env = append(cmd.Env, h.Env...)
+	if !hasEnv(h.Env, "PATH") {
+		cmd.Env = append(cmd.Env, "PATH="+os.Getenv("PATH"))
+	}
+	for _, v := range cgiEnv(r, h.Root) {
+		cmd.Env = append(cmd.Env, v[0]+"="+v[1])
+	}
+	stderr := &logLines{logger: h.ErrorLog, prefix: "gemini: cgi " + h.Path + ": "}
+
+	// The pipes are read here rather than by cmd.Wait and closed when ctx is
+	// done, so children of the script holding them open can't outlast the
+	// timeout.
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		logf(h.ErrorLog, "gemini: cgi %s: %v", h.Path, err)
+		cgiError(w)
+		return
+	}
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		logf(h.ErrorLog, "gemini: cgi %s: %v", h.Path, err)
+		cgiError(w)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		logf(h.ErrorLog, "gemini: cgi %s: %v", h.Path, err)
+		cgiError(w)
+		return
+	}
+	stopStdout := closeOnDone(ctx, stdout)
+	defer stopStdout()
+	stopStderr := closeOnDone(ctx, stderrPipe)
+	defer stopStderr()
+	stderrDone := make(chan struct{})
+	go func() {
+		io.Copy(stderr, stderrPipe)
+		close(stderrDone)
+	}()
+
+	copyResponse(w, stdout, h.ErrorLog, "cgi "+h.Path)
+	<-stderrDone
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = ctx.Err()
+		}
+		logf(h.ErrorLog, "gemini: cgi %s: %v", h.Path, err)
+	}
+	stderr.flush()
+}
+
+// SCGIHandler serves requests by forwarding them to an SCGI backend. The
+// backend gets the Gemini CGI environment as SCGI headers and answers with a
+// Gemini response. A backend that can't be reached or doesn't answer with a
+// valid header gets 42 CGI ERROR.
+type SCGIHandler struct {
+	// Network is the network of the backend, "tcp" if empty or "unix".
+	Network string
+	// Addr is the address of the backend.
+	Addr string
+	// Root is the url path the backend is mounted at. The rest of the path is PATH_INFO.
+	Root string
+	// Timeout limits the whole exchange with the backend. If zero, DEFAULT_CGI_TIMEOUT is used.
+	Timeout time.Duration
+	// ErrorLog logs backend failures. If nil, the log package's logger is used.
+	ErrorLog *log.Logger
+}
+
+func (h *SCGIHandler) ServeGemini(w ResponseWriter, r *Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), cgiTimeout(h.Timeout))
+	defer cancel()
+
+	network := h.Network
+	if len(network) == 0 {
+		network = "tcp"
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, h.Addr)
+	if err != nil {
+		logf(h.ErrorLog, "gemini: scgi %s: %v", h.Addr, err)
+		cgiError(w)
+		return
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	if _, err := conn.Write(scgiHeaders(r, h.Root)); err != nil {
+		logf(h.ErrorLog, "gemini: scgi %s: %v", h.Addr, err)
+		cgiError(w)
+		return
+	}
+	copyResponse(w, conn, h.ErrorLog, "scgi "+h.Addr)
+}
+
+// scgiHeaders encodes the request environment as an SCGI netstring.
+// CONTENT_LENGTH comes first as the protocol requires.
+func scgiHeaders(r *Request, root string) []byte {
+	var headers strings.Builder
+	writeHeader := func(name string, value string) {
+		headers.WriteString(name)
+		headers.WriteByte(0)
+		headers.WriteString(value)
+		headers.WriteByte(0)
+	}
+	writeHeader("CONTENT_LENGTH", "0")
+	writeHeader("SCGI", "1")
+	for _, v := range cgiEnv(r, root) {
+		writeHeader(v[0], v[1])
+	}
+	return []byte(strconv.Itoa(headers.Len()) + ":" + headers.String() + ",")
+}
+
+// cgiEnv gets the Gemini CGI environment of a request as name and value pairs.
+// PATH_INFO is the path after root only if root ends at a path segment.
+func cgiEnv(r *Request, root string) [][2]string {
+	root = strings.TrimSuffix(root, "/")
+	port := r.Url.Port()
+	if len(port) == 0 {
+		port = gemini.DEFAULT_PORT
+	}
+	remoteHost := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		remoteHost = host
+	}
+
+	pathInfo := r.Url.Path
+	if rest := strings.TrimPrefix(pathInfo, root); len(rest) == 0 || strings.HasPrefix(rest, "/") {
+		pathInfo = rest
+	}
+
+	env := [][2]string{
+		{"GATEWAY_INTERFACE", "CGI/1.1"},
+		{"SERVER_PROTOCOL", "GEMINI"},
+		{"SERVER_SOFTWARE", "hikawa"},
+		{"SERVER_NAME", r.Url.Hostname()},
+		{"SERVER_PORT", port},
+		{"GEMINI_URL", r.Url.String()},
+		{"SCRIPT_NAME", root},
+		{"PATH_INFO", pathInfo},
+		{"QUERY_STRING", r.Url.RawQuery},
+		{"REMOTE_ADDR", remoteHost},
+		{"REMOTE_HOST", remoteHost},
+	}
+	if r.Certificate != nil {
+		env = append(env,
+			[2]string{"AUTH_TYPE", "CERTIFICATE"},
+			[2]string{"REMOTE_USER", r.Certificate.Subject.CommonName},
+			[2]string{"TLS_CLIENT_HASH", "SHA256:" + gemini.Fingerprint(r.Certificate)},
+			[2]string{"TLS_CLIENT_NOT_BEFORE", r.Certificate.NotBefore.UTC().Format(time.RFC3339)},
+			[2]string{"TLS_CLIENT_NOT_AFTER", r.Certificate.NotAfter.UTC().Format(time.RFC3339)},
+		)
+	}
+	return env
+}
+
+// copyResponse reads a Gemini response from a script or backend and writes it
+// to w, or writes 42 CGI ERROR if the header is invalid.
+func copyResponse(w ResponseWriter, src io.Reader, logger *log.Logger, name string) {
+	resp, err := gemini.ReadResponse(bufio.NewReader(src))
+	if err != nil {
+		logf(logger, "gemini: %s: invalid response header: %v", name, err)
+		cgiError(w)
+		io.Copy(io.Discard, src)
+		return
+	}
+	w.WriteHeader(resp.Header.Status, resp.Header.Meta)
+	if resp.Header.Status.IsSuccess() {
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			logf(logger, "gemini: %s: %v", name, err)
+		}
+	}
+	io.Copy(io.Discard, src)
+}
+
+func cgiError(w ResponseWriter) {
+	w.WriteHeader(gemini.STATUS_CGI_ERROR, "CGI error")
+}
+
+func cgiTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return DEFAULT_CGI_TIMEOUT
+	}
+	return timeout
+}
+
+// hasEnv checks if env sets a variable.
+func hasEnv(env []string, name string) bool {
+	for _, v := range env {
+		if strings.HasPrefix(v, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// closeOnDone closes c when ctx is done. The returned function stops watching ctx.
+func closeOnDone(ctx context.Context, c io.Closer) func() {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-stop:
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// logLines logs each line written to it.
+type logLines struct {
+	logger  *log.Logger
+	prefix  string
+	pending []byte
+}
+
+func (l *logLines) Write(p []byte) (int, error) {
+	l.pending = append(l.pending, p...)
+	for {
+		i := bytes.IndexByte(l.pending, '\n')
+		if i < 0 {
+			break
+		}
+		logf(l.logger, "%s%s", l.prefix, l.pending[:i])
+		l.pending = l.pending[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush logs the last line if it did not end with a newline.
+func (l *logLines) flush() {
+	if len(l.pending) > 0 {
+		logf(l.logger, "%s%s", l.prefix, l.pending)
+		l.pending = nil
+	}
+}
@@ -0,0 +1,249 @@
+package server_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jasmaa/hikawa/internal/testcert"
+	"github.com/jasmaa/hikawa/internal/testserver"
+	"github.com/jasmaa/hikawa/pkg/gemini"
+	"github.com/jasmaa/hikawa/pkg/gemini/server"
+	"github.com/stretchr/testify/assert"
+)
+
+// cgiVariables are the variables the helper script echoes.
+var cgiVariables = []string{
+	"GATEWAY_INTERFACE", "SERVER_NAME", "SERVER_PORT", "GEMINI_URL", "SCRIPT_NAME", "PATH_INFO",
+	"QUERY_STRING", "REMOTE_ADDR", "AUTH_TYPE", "REMOTE_USER", "TLS_CLIENT_HASH",
+}
+
+// TestCGIHelperProcess is the script run by the CGI tests.
+func TestCGIHelperProcess(t *testing.T) {
+	if os.Getenv("HIKAWA_CGI_HELPER") != "1" {
+		return
+	}
+	switch os.Getenv("PATH_INFO") {
+	case "/env":
+		fmt.Print("20 text/plain\r\n")
+		for _, name := range cgiVariables {
+			fmt.Printf("%s=%s\n", name, os.Getenv(name))
+		}
+	case "/input":
+		fmt.Print("10 Search for\r\n")
+	case "/bad":
+		fmt.Print("hello\n")
+	case "/slow":
+		time.Sleep(10 * time.Second)
+	default:
+		fmt.Fprintln(os.Stderr, "no such page")
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// helperCGI makes a handler that runs TestCGIHelperProcess.
+func helperCGI(logger *log.Logger) *server.CGIHandler {
+	return &server.CGIHandler{
+		Path:     os.Args[0],
+		Root:     "/cgi-bin/helper",
+		Args:     []string{"-test.run=TestCGIHelperProcess"},
+		Env:      []string{"HIKAWA_CGI_HELPER=1"},
+		ErrorLog: logger,
+	}
+}
+
+// certificateRequest makes a request with a client certificate.
+func certificateRequest(t *testing.T, rawurl string) *server.Request {
+	cert, err := x509.ParseCertificate(testcert.New(t, time.Now().Add(time.Hour)).Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &server.Request{Url: u, RemoteAddr: "192.0.2.1:51000", Certificate: cert}
+}
+
+// parseVariables parses the variables echoed by the helper script.
+func parseVariables(body string) map[string]string {
+	variables := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		if name, value, ok := strings.Cut(scanner.Text(), "="); ok {
+			variables[name] = value
+		}
+	}
+	return variables
+}
+
+// TestCGIEnvironment tests running a script with the Gemini CGI environment.
+func TestCGIEnvironment(t *testing.T) {
+	r := certificateRequest(t, "gemini://example.org/cgi-bin/helper/env?q%20a")
+	w := &recorder{}
+	helperCGI(nil).ServeGemini(w, r)
+	assert.Equal(t, gemini.STATUS_SUCCESS, w.status)
+	assert.Equal(t, "text/plain", w.meta)
+	assert.Equal(t, map[string]string{
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"SERVER_NAME":       "example.org",
+		"SERVER_PORT":       "1965",
+		"GEMINI_URL":        "gemini://example.org/cgi-bin/helper/env?q%20a",
+		"SCRIPT_NAME":       "/cgi-bin/helper",
+		"PATH_INFO":         "/env",
+		"QUERY_STRING":      "q%20a",
+		"REMOTE_ADDR":       "192.0.2.1",
+		"AUTH_TYPE":         "CERTIFICATE",
+		"REMOTE_USER":       "localhost",
+		"TLS_CLIENT_HASH":   "SHA256:" + gemini.Fingerprint(r.Certificate),
+	}, parseVariables(w.body.String()))
+}
+
+// TestCGIStatus tests passing a non-success header through.
+func TestCGIStatus(t *testing.T) {
+	w := serve(t, helperCGI(nil), "gemini://example.org/cgi-bin/helper/input")
+	assert.Equal(t, gemini.STATUS_INPUT, w.status)
+	assert.Equal(t, "Search for", w.meta)
+	assert.Equal(t, "", w.body.String())
+}
+
+// TestCGIErrors tests answering failed scripts with 42 CGI ERROR.
+func TestCGIErrors(t *testing.T) {
+	var logs bytes.Buffer
+	logger := log.New(&logs, "", 0)
+
+	w := serve(t, helperCGI(logger), "gemini://example.org/cgi-bin/helper/bad")
+	assert.Equal(t, gemini.STATUS_CGI_ERROR, w.status)
+
+	w = serve(t, helperCGI(logger), "gemini://example.org/cgi-bin/helper/missing")
+	assert.Equal(t, gemini.STATUS_CGI_ERROR, w.status)
+	assert.Contains(t, logs.String(), "no such page")
+
+	handler := helperCGI(logger)
+	handler.Path = os.Args[0] + "-missing"
+	w = serve(t, handler, "gemini://example.org/cgi-bin/helper/env")
+	assert.Equal(t, gemini.STATUS_CGI_ERROR, w.status)
+}
+
+// TestCGITimeout tests stopping slow scripts.
+func TestCGITimeout(t *testing.T) {
+	handler := helperCGI(log.New(io.Discard, "", 0))
+	handler.Timeout = 100 * time.Millisecond
+	start := time.Now()
+	w := serve(t, handler, "gemini://example.org/cgi-bin/helper/slow")
+	assert.Equal(t, gemini.STATUS_CGI_ERROR, w.status)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+// TestCGITimeoutChild tests stopping scripts whose children keep stdout open.
+func TestCGITimeoutChild(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	script := filepath.Join(t.TempDir(), "fork.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nsleep 60 &\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	handler := &server.CGIHandler{Path: script, Timeout: 100 * time.Millisecond, ErrorLog: log.New(io.Discard, "", 0)}
+	start := time.Now()
+	w := serve(t, handler, "gemini://example.org/")
+	assert.Equal(t, gemini.STATUS_CGI_ERROR, w.status)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+// serveSCGI serves an SCGI backend that answers with respond and returns its address.
+func serveSCGI(t *testing.T, respond func(headers map[string]string, order []string) string) string {
+	return testserver.TCP(t, func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		length, err := reader.ReadString(':')
+		if err != nil {
+			return
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(length, ":"))
+		if err != nil {
+			return
+		}
+		data := make([]byte, n+1)
+		if _, err := io.ReadFull(reader, data); err != nil || data[n] != ',' {
+			return
+		}
+		fields := strings.Split(string(data[:n]), "\x00")
+		headers := make(map[string]string)
+		var order []string
+		for i := 0; i+1 < len(fields); i += 2 {
+			headers[fields[i]] = fields[i+1]
+			order = append(order, fields[i])
+		}
+		conn.Write([]byte(respond(headers, order)))
+	})
+}
+
+// TestSCGI tests forwarding a request to an SCGI backend.
+func TestSCGI(t *testing.T) {
+	addr := serveSCGI(t, func(headers map[string]string, order []string) string {
+		return fmt.Sprintf("20 text/gemini\r\n%s %s %s %s %s", order[0], headers["SCGI"], headers["PATH_INFO"], headers["QUERY_STRING"], headers["TLS_CLIENT_HASH"])
+	})
+	r := certificateRequest(t, "gemini://example.org/app/guestbook?hello")
+	w := &recorder{}
+	(&server.SCGIHandler{Addr: addr, Root: "/app/"}).ServeGemini(w, r)
+	assert.Equal(t, gemini.STATUS_SUCCESS, w.status)
+	assert.Equal(t, "CONTENT_LENGTH 1 /guestbook hello SHA256:"+gemini.Fingerprint(r.Certificate), w.body.String())
+}
+
+// TestSCGIPathInfo tests only stripping the root at a path segment boundary.
+func TestSCGIPathInfo(t *testing.T) {
+	addr := serveSCGI(t, func(headers map[string]string, order []string) string {
+		return "20 text/gemini\r\n" + headers["PATH_INFO"]
+	})
+	tests := map[string]string{
+		"gemini://example.org/cgi":       "",
+		"gemini://example.org/cgi/":      "/",
+		"gemini://example.org/cgi/a":     "/a",
+		"gemini://example.org/cgiextra":  "/cgiextra",
+		"gemini://example.org/other/cgi": "/other/cgi",
+	}
+	for rawurl, pathInfo := range tests {
+		w := serve(t, &server.SCGIHandler{Addr: addr, Root: "/cgi"}, rawurl)
+		assert.Equal(t, pathInfo, w.body.String(), rawurl)
+	}
+}
+
+// TestSCGIErrors tests answering backend failures with 42 CGI ERROR.
+func TestSCGIErrors(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	addr := serveSCGI(t, func(headers map[string]string, order []string) string {
+		return ""
+	})
+	w := serve(t, &server.SCGIHandler{Addr: addr, ErrorLog: logger}, "gemini://example.org/")
+	assert.Equal(t, gemini.STATUS_CGI_ERROR, w.status)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := listener.Addr().String()
+	listener.Close()
+	w = serve(t, &server.SCGIHandler{Addr: closedAddr, ErrorLog: logger}, "gemini://example.org/")
+	assert.Equal(t, gemini.STATUS_CGI_ERROR, w.status)
+
+	listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	w = serve(t, &server.SCGIHandler{Addr: listener.Addr().String(), Timeout: 100 * time.Millisecond, ErrorLog: logger}, "gemini://example.org/")
+	assert.Equal(t, gemini.STATUS_CGI_ERROR, w.status)
+}
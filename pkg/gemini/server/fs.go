@@ -0,0 +1,107 @@
+package server
+
+import (
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/jasmaa/hikawa/internal/capsule"
+	"github.com/jasmaa/hikawa/pkg/gemini"
+)
+
+// INDEX_FILE is served for a directory when it exists.
+const INDEX_FILE = "index.gmi"
+
+// FileServer serves files from root. Directories are served by their
+// INDEX_FILE, or else by a generated gemtext listing. Files starting with a
+// dot are not served.
+func FileServer(root fs.FS) Handler {
+	return &fileHandler{root: root}
+}
+
+type fileHandler struct {
+	root fs.FS
+}
+
+func (f *fileHandler) ServeGemini(w ResponseWriter, r *Request) {
+	requestPath := r.Url.Path
+	if !strings.HasPrefix(requestPath, "/") {
+		requestPath = "/" + requestPath
+	}
+	name := strings.TrimPrefix(path.Clean(requestPath), "/")
+	if len(name) == 0 {
+		name = "."
+	}
+	if !fs.ValidPath(name) || isHidden(name) {
+		NotFound(w, r)
+		return
+	}
+
+	info, err := fs.Stat(f.root, name)
+	if err != nil {
+		NotFound(w, r)
+		return
+	}
+	if !info.IsDir() {
+		f.serveFile(w, r, name)
+		return
+	}
+
+	if !strings.HasSuffix(requestPath, "/") {
+		redirect := (&url.URL{Path: path.Base(requestPath) + "/"}).EscapedPath()
+		w.WriteHeader(gemini.STATUS_REDIRECT_PERMANENT, redirect)
+		return
+	}
+	index := path.Join(name, INDEX_FILE)
+	if info, err := fs.Stat(f.root, index); err == nil && !info.IsDir() {
+		f.serveFile(w, r, index)
+		return
+	}
+	f.serveDirectory(w, r, name, requestPath)
+}
+
+// serveFile serves a file with its media type.
+func (f *fileHandler) serveFile(w ResponseWriter, r *Request, name string) {
+	file, err := f.root.Open(name)
+	if err != nil {
+		NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	head := make([]byte, capsule.SNIFF_LENGTH)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		w.WriteHeader(gemini.STATUS_TEMPORARY_FAILURE, "Could not read file")
+		return
+	}
+	head = head[:n]
+
+	w.WriteHeader(gemini.STATUS_SUCCESS, capsule.MediaType(name, head))
+	w.Write(head)
+	io.Copy(w, file)
+}
+
+// serveDirectory serves a gemtext listing of a directory.
+func (f *fileHandler) serveDirectory(w ResponseWriter, r *Request, name string, requestPath string) {
+	entries, err := fs.ReadDir(f.root, name)
+	if err != nil {
+		w.WriteHeader(gemini.STATUS_TEMPORARY_FAILURE, "Could not read directory")
+		return
+	}
+
+	w.WriteHeader(gemini.STATUS_SUCCESS, "text/gemini")
+	io.WriteString(w, capsule.DirectoryIndex(requestPath, entries))
+}
+
+// isHidden checks if any element of a path starts with a dot.
+func isHidden(name string) bool {
+	for _, element := range strings.Split(name, "/") {
+		if strings.HasPrefix(element, ".") && element != "." {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package server_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/jasmaa/hikawa/pkg/gemini"
+	"github.com/jasmaa/hikawa/pkg/gemini/server"
+	"github.com/stretchr/testify/assert"
+)
+
+// capsule is a small capsule to serve.
+var capsule = fstest.MapFS{
+	"index.gmi":           {Data: []byte("# Home\n")},
+	"notes.txt":           {Data: []byte("plain notes")},
+	"image.png":           {Data: []byte("\x89PNG\r\n\x1a\n")},
+	"unknown":             {Data: []byte("%PDF-1.4")},
+	".secret":             {Data: []byte("hidden")},
+	"docs/guide.gemini":   {Data: []byte("# Guide\n")},
+	"docs/a b.txt":        {Data: []byte("spaced")},
+	"docs/sub/deep.gmi":   {Data: []byte("deep")},
+	"docs/.hidden/x.gmi":  {Data: []byte("hidden")},
+	"empty/placeholder.o": {Data: []byte{}},
+}
+
+// TestFileServerFiles tests serving files with their media types.
+func TestFileServerFiles(t *testing.T) {
+	tests := []struct {
+		path      string
+		mediaType string
+		body      string
+	}{
+		{"/", "text/gemini", "# Home\n"},
+		{"/index.gmi", "text/gemini", "# Home\n"},
+		{"/notes.txt", "text/plain; charset=utf-8", "plain notes"},
+		{"/image.png", "image/png", "\x89PNG\r\n\x1a\n"},
+		{"/unknown", "application/pdf", "%PDF-1.4"},
+		{"/docs/guide.gemini", "text/gemini", "# Guide\n"},
+		{"/docs/a%20b.txt", "text/plain; charset=utf-8", "spaced"},
+	}
+	handler := server.FileServer(capsule)
+	for _, test := range tests {
+		w := serve(t, handler, "gemini://example.org"+test.path)
+		if assert.Equal(t, gemini.STATUS_SUCCESS, w.status, test.path) {
+			assert.Equal(t, test.mediaType, w.meta, test.path)
+			assert.Equal(t, test.body, w.body.String(), test.path)
+		}
+	}
+}
+
+// TestFileServerDirectoryIndex tests listing a directory without an index file.
+func TestFileServerDirectoryIndex(t *testing.T) {
+	w := serve(t, server.FileServer(capsule), "gemini://example.org/docs/")
+	assert.Equal(t, gemini.STATUS_SUCCESS, w.status)
+	assert.Equal(t, "text/gemini", w.meta)
+	assert.Equal(t, "# Index of /docs/\n\n"+
+		"=> ../ ../\n"+
+		"=> a%20b.txt a b.txt\n"+
+		"=> guide.gemini guide.gemini\n"+
+		"=> sub/ sub/\n", w.body.String())
+}
+
+// TestFileServerDirectoryRedirect tests redirecting a directory to its trailing slash.
+func TestFileServerDirectoryRedirect(t *testing.T) {
+	w := serve(t, server.FileServer(capsule), "gemini://example.org/docs")
+	assert.Equal(t, gemini.STATUS_REDIRECT_PERMANENT, w.status)
+	assert.Equal(t, "docs/", w.meta)
+}
+
+// TestFileServerNotFound tests hiding missing and dot files.
+func TestFileServerNotFound(t *testing.T) {
+	for _, path := range []string{"/missing.gmi", "/.secret", "/docs/.hidden/x.gmi", "/../index.gmi"} {
+		w := serve(t, server.FileServer(capsule), "gemini://example.org"+path)
+		if path == "/../index.gmi" {
+			// Cleaned to the root index
+			assert.Equal(t, gemini.STATUS_SUCCESS, w.status, path)
+			continue
+		}
+		assert.Equal(t, gemini.STATUS_NOT_FOUND, w.status, path)
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/jasmaa/hikawa/pkg/gemini"
+)
+
+// ServeMux sends requests to the handler whose pattern best matches the url.
+//
+// Patterns are paths like "/about.gmi", which match that path only, or
+// subtrees ending in a slash like "/files/", which match every path under
+// them. The longest matching pattern wins. A pattern may start with a host,
+// as in "example.org/", to only match requests for that host. A request for
+// a subtree without its trailing slash is redirected to the subtree.
+type ServeMux struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// NewServeMux creates an empty ServeMux.
+func NewServeMux() *ServeMux {
+	return &ServeMux{
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Handle registers a handler for a pattern. It panics if the pattern is
+// empty or already registered.
+func (m *ServeMux) Handle(pattern string, handler Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(pattern) == 0 {
+		panic("gemini: empty pattern")
+	}
+	if handler == nil {
+		panic("gemini: nil handler")
+	}
+	if _, ok := m.handlers[pattern]; ok {
+		panic("gemini: multiple registrations for " + pattern)
+	}
+	m.handlers[pattern] = handler
+}
+
+// HandleFunc registers a handler function for a pattern.
+func (m *ServeMux) HandleFunc(pattern string, handler func(w ResponseWriter, r *Request)) {
+	m.Handle(pattern, HandlerFunc(handler))
+}
+
+// ServeGemini sends the request to the matching handler, or responds with 51 NOT FOUND.
+func (m *ServeMux) ServeGemini(w ResponseWriter, r *Request) {
+	requestPath := r.Url.Path
+	if len(requestPath) == 0 {
+		requestPath = "/"
+	}
+	if cleaned := cleanPath(requestPath); cleaned != requestPath {
+		redirectTo(w, r, cleaned)
+		return
+	}
+
+	handler, redirect := m.match(strings.ToLower(r.Url.Hostname()), requestPath)
+	if len(redirect) > 0 {
+		redirectTo(w, r, redirect)
+		return
+	}
+	if handler == nil {
+		NotFound(w, r)
+		return
+	}
+	handler.ServeGemini(w, r)
+}
+
+// redirectTo permanently redirects a request to another path on the same host.
+func redirectTo(w ResponseWriter, r *Request, redirectPath string) {
+	target := *r.Url
+	target.Path = redirectPath
+	target.RawPath = ""
+	w.WriteHeader(gemini.STATUS_REDIRECT_PERMANENT, target.String())
+}
+
+// match finds the handler for a host and path. If only the subtree of the
+// path is registered, the path to redirect to is returned instead.
+func (m *ServeMux) match(host string, requestPath string) (Handler, string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var handler Handler
+	longest := -1
+	for pattern, h := range m.handlers {
+		patternPath := pattern
+		if !strings.HasPrefix(pattern, "/") {
+			i := strings.Index(pattern, "/")
+			if i < 0 || !strings.EqualFold(pattern[:i], host) {
+				continue
+			}
+			patternPath = pattern[i:]
+		}
+		if !pathMatches(patternPath, requestPath) || len(pattern) <= longest {
+			continue
+		}
+		handler = h
+		longest = len(pattern)
+	}
+	if handler != nil || strings.HasSuffix(requestPath, "/") {
+		return handler, ""
+	}
+
+	subtree := requestPath + "/"
+	for pattern := range m.handlers {
+		if pattern == subtree || strings.EqualFold(pattern, host+subtree) {
+			return nil, subtree
+		}
+	}
+	return nil, ""
+}
+
+// pathMatches checks if a pattern path matches a request path.
+func pathMatches(patternPath string, requestPath string) bool {
+	if strings.HasSuffix(patternPath, "/") {
+		return strings.HasPrefix(requestPath, patternPath)
+	}
+	return patternPath == requestPath
+}
+
+// cleanPath resolves dot segments and repeated slashes, keeping a trailing slash.
+func cleanPath(p string) string {
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	cleaned := path.Clean(p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// StripPrefix serves requests with prefix removed from the path, so a
+// handler can be mounted under a subtree. Paths without the prefix get 51 NOT FOUND.
+func StripPrefix(prefix string, handler Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		if !strings.HasPrefix(r.Url.Path, prefix) {
+			NotFound(w, r)
+			return
+		}
+		stripped := *r
+		strippedUrl := *r.Url
+		strippedUrl.Path = strings.TrimPrefix(r.Url.Path, prefix)
+		strippedUrl.RawPath = ""
+		stripped.Url = &strippedUrl
+		handler.ServeGemini(w, &stripped)
+	})
+}
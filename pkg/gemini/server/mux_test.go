@@ -0,0 +1,125 @@
+package server_test
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+
+	"github.com/jasmaa/hikawa/pkg/gemini"
+	"github.com/jasmaa/hikawa/pkg/gemini/server"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder is a ResponseWriter that records the response.
+type recorder struct {
+	status gemini.Status
+	meta   string
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status gemini.Status, meta string) {
+	if r.status == 0 {
+		r.status = status
+		r.meta = meta
+	}
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(gemini.STATUS_SUCCESS, "text/gemini")
+	}
+	return r.body.Write(p)
+}
+
+func (r *recorder) Flush() error {
+	return nil
+}
+
+// serve serves a request for rawurl with handler and records the response.
+func serve(t *testing.T, handler server.Handler, rawurl string) *recorder {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &recorder{}
+	handler.ServeGemini(w, &server.Request{Url: u})
+	return w
+}
+
+// named responds with its name.
+func named(name string) server.Handler {
+	return server.HandlerFunc(func(w server.ResponseWriter, r *server.Request) {
+		w.Write([]byte(name))
+	})
+}
+
+// TestServeMuxPatterns tests matching the longest pattern.
+func TestServeMuxPatterns(t *testing.T) {
+	mux := server.NewServeMux()
+	mux.Handle("/", named("root"))
+	mux.Handle("/about.gmi", named("about"))
+	mux.Handle("/files/", named("files"))
+	mux.Handle("/files/private/", named("private"))
+	mux.Handle("other.example/", named("other"))
+
+	tests := []struct {
+		rawurl string
+		name   string
+	}{
+		{"gemini://example.org/", "root"},
+		{"gemini://example.org", "root"},
+		{"gemini://example.org/about.gmi", "about"},
+		{"gemini://example.org/about.gmi/more", "root"},
+		{"gemini://example.org/files/", "files"},
+		{"gemini://example.org/files/a/b.txt", "files"},
+		{"gemini://example.org/files/private/key", "private"},
+		{"gemini://Other.example/about.gmi", "other"},
+	}
+	for _, test := range tests {
+		w := serve(t, mux, test.rawurl)
+		assert.Equal(t, gemini.STATUS_SUCCESS, w.status, test.rawurl)
+		assert.Equal(t, test.name, w.body.String(), test.rawurl)
+	}
+}
+
+// TestServeMuxNotFound tests answering unmatched paths with 51 NOT FOUND.
+func TestServeMuxNotFound(t *testing.T) {
+	mux := server.NewServeMux()
+	mux.Handle("/about.gmi", named("about"))
+	w := serve(t, mux, "gemini://example.org/missing")
+	assert.Equal(t, gemini.STATUS_NOT_FOUND, w.status)
+}
+
+// TestServeMuxRedirects tests redirecting to subtrees and cleaned paths.
+func TestServeMuxRedirects(t *testing.T) {
+	mux := server.NewServeMux()
+	mux.Handle("/files/", named("files"))
+
+	w := serve(t, mux, "gemini://example.org/files?q")
+	assert.Equal(t, gemini.STATUS_REDIRECT_PERMANENT, w.status)
+	assert.Equal(t, "gemini://example.org/files/?q", w.meta)
+
+	w = serve(t, mux, "gemini://example.org/files/a/../b//c")
+	assert.Equal(t, gemini.STATUS_REDIRECT_PERMANENT, w.status)
+	assert.Equal(t, "gemini://example.org/files/b/c", w.meta)
+}
+
+// TestServeMuxDuplicate tests panicking on a pattern registered twice.
+func TestServeMuxDuplicate(t *testing.T) {
+	mux := server.NewServeMux()
+	mux.Handle("/", named("a"))
+	assert.Panics(t, func() {
+		mux.Handle("/", named("b"))
+	})
+}
+
+// TestStripPrefix tests mounting a handler under a subtree.
+func TestStripPrefix(t *testing.T) {
+	handler := server.StripPrefix("/static", server.HandlerFunc(func(w server.ResponseWriter, r *server.Request) {
+		w.Write([]byte(r.Url.Path))
+	}))
+	w := serve(t, handler, "gemini://example.org/static/a.txt")
+	assert.Equal(t, "/a.txt", w.body.String())
+	w = serve(t, handler, "gemini://example.org/other")
+	assert.Equal(t, gemini.STATUS_NOT_FOUND, w.status)
+}
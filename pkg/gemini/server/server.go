@@ -0,0 +1,428 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jasmaa/hikawa/pkg/gemini"
+)
+
+// DEFAULT_ADDR is the address servers listen on when none is given.
+const DEFAULT_ADDR = ":" + gemini.DEFAULT_PORT
+
+// ErrServerClosed is returned by Serve after Shutdown or Close.
+var ErrServerClosed = errors.New("gemini: server closed")
+
+// ErrBodyNotAllowed is returned by ResponseWriter.Write for non-2X responses.
+var ErrBodyNotAllowed = errors.New("gemini: response status does not allow a body")
+
+// Request is a request received by a Server.
+type Request struct {
+	Url *url.URL
+	// RemoteAddr is the address of the client.
+	RemoteAddr string
+	// TLS is the state of the connection.
+	TLS *tls.ConnectionState
+	// Certificate is the client certificate, if one was presented.
+	Certificate *x509.Certificate
+	ctx         context.Context
+}
+
+// Context gets the request context. It is cancelled when the handler returns
+// or the server is closed, or when Shutdown gives up waiting. A client hanging
+// up does not cancel it; writes to the closed connection fail instead.
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// ResponseWriter writes a response to a Request.
+type ResponseWriter interface {
+	// WriteHeader writes the response header. Only the first call has an effect.
+	// Line breaks in meta are replaced with spaces and meta is cut to
+	// MAX_META_LENGTH bytes.
+	WriteHeader(status gemini.Status, meta string)
+	// Write writes the body, writing a 20 text/gemini header first if none was written.
+	Write(p []byte) (int, error)
+	// Flush sends buffered data to the client.
+	Flush() error
+}
+
+// Handler responds to a Request.
+type Handler interface {
+	ServeGemini(w ResponseWriter, r *Request)
+}
+
+// HandlerFunc is a function used as a Handler.
+type HandlerFunc func(w ResponseWriter, r *Request)
+
+// ServeGemini calls f(w, r).
+func (f HandlerFunc) ServeGemini(w ResponseWriter, r *Request) {
+	f(w, r)
+}
+
+// NotFound responds with 51 NOT FOUND.
+func NotFound(w ResponseWriter, r *Request) {
+	w.WriteHeader(gemini.STATUS_NOT_FOUND, "Not found")
+}
+
+// Server serves Gemini requests over TLS.
+type Server struct {
+	// Addr is the address to listen on. If empty, DEFAULT_ADDR is used.
+	Addr string
+	// Handler responds to requests.
+	Handler Handler
+	// TLSConfig holds the server certificates. Client certificates are
+	// requested but not verified unless ClientAuth is set.
+	TLSConfig *tls.Config
+	// ReadTimeout limits the handshake and reading the request line. Zero means no limit.
+	ReadTimeout time.Duration
+	// WriteTimeout limits writing the response. Zero means no limit.
+	WriteTimeout time.Duration
+	// ErrorLog logs handler panics. If nil, the log package's logger is used.
+	ErrorLog *log.Logger
+	// Hosts are the hostnames the server answers for. Requests for other
+	// hosts get 53 PROXY REQUEST REFUSED. If empty, any host is served.
+	Hosts []string
+
+	mu         sync.Mutex
+	closed     bool
+	listeners  map[net.Listener]struct{}
+	conns      map[net.Conn]context.CancelFunc
+	activeConn sync.WaitGroup
+}
+
+// ListenAndServe listens on Addr and serves requests with TLSConfig.
+func (s *Server) ListenAndServe() error {
+	addr := s.Addr
+	if len(addr) == 0 {
+		addr = DEFAULT_ADDR
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// ListenAndServeTLS listens on Addr and serves requests with the certificate
+// and key in PEM files.
+func (s *Server) ListenAndServeTLS(certFile string, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	config := &tls.Config{}
+	if s.TLSConfig != nil {
+		config = s.TLSConfig.Clone()
+	}
+	config.Certificates = append(config.Certificates, cert)
+	s.TLSConfig = config
+	return s.ListenAndServe()
+}
+
+// Serve accepts TCP connections on l and serves requests over TLS.
+// It always returns an error, ErrServerClosed after Shutdown or Close.
+func (s *Server) Serve(l net.Listener) error {
+	if s.TLSConfig == nil || (len(s.TLSConfig.Certificates) == 0 && s.TLSConfig.GetCertificate == nil) {
+		l.Close()
+		return errors.New("gemini: server has no certificate")
+	}
+	config := s.TLSConfig.Clone()
+	if config.ClientAuth == tls.NoClientCert {
+		config.ClientAuth = tls.RequestClientCert
+	}
+
+	if !s.trackListener(l, true) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer s.trackListener(l, false)
+
+	tlsListener := tls.NewListener(l, config)
+	for {
+		conn, err := tlsListener.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		ctx, ok := s.trackConn(conn)
+		if !ok {
+			conn.Close()
+			continue
+		}
+		go s.serveConn(ctx, conn)
+	}
+}
+
+// Shutdown stops accepting connections and waits for active requests to
+// finish. If ctx is done first, the remaining connections are closed and
+// ctx.Err() returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.closeListeners()
+	done := make(chan struct{})
+	go func() {
+		s.activeConn.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.closeConns()
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting connections and closes active connections immediately.
+func (s *Server) Close() error {
+	s.closeListeners()
+	s.closeConns()
+	return nil
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// trackListener adds or removes a listener, returning false if the server is closed.
+func (s *Server) trackListener(l net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
+	}
+	if !add {
+		delete(s.listeners, l)
+		return true
+	}
+	if s.closed {
+		return false
+	}
+	s.listeners[l] = struct{}{}
+	return true
+}
+
+// trackConn adds an active connection, returning false if the server is closed.
+func (s *Server) trackConn(conn net.Conn) (context.Context, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, false
+	}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]context.CancelFunc)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.conns[conn] = cancel
+	s.activeConn.Add(1)
+	return ctx, true
+}
+
+func (s *Server) untrackConn(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.conns[conn]; ok {
+		cancel()
+		delete(s.conns, conn)
+		s.activeConn.Done()
+	}
+}
+
+func (s *Server) closeListeners() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+}
+
+func (s *Server) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn, cancel := range s.conns {
+		cancel()
+		conn.Close()
+	}
+}
+
+// logf logs to logger, or to the log package's logger if it is nil.
+func logf(logger *log.Logger, format string, args ...interface{}) {
+	if logger != nil {
+		logger.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// serveConn serves the single request on a connection.
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer s.untrackConn(conn)
+	defer conn.Close()
+
+	if s.ReadTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(s.ReadTimeout))
+	}
+	tlsConn := conn.(*tls.Conn)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return
+	}
+	if s.WriteTimeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
+	}
+
+	w := &response{writer: bufio.NewWriter(conn)}
+	defer w.finish()
+
+	u, err := readRequest(bufio.NewReader(conn))
+	if err != nil {
+		w.WriteHeader(gemini.STATUS_BAD_REQUEST, err.Error())
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+	if u.Scheme != "gemini" || !s.servesHost(u.Hostname()) {
+		w.WriteHeader(gemini.STATUS_PROXY_REQUEST_REFUSED, "Proxy request refused")
+		return
+	}
+
+	state := tlsConn.ConnectionState()
+	r := &Request{
+		Url:        u,
+		RemoteAddr: conn.RemoteAddr().String(),
+		TLS:        &state,
+		ctx:        ctx,
+	}
+	if len(state.PeerCertificates) > 0 {
+		r.Certificate = state.PeerCertificates[0]
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			logf(s.ErrorLog, "gemini: panic serving %s: %v", r.Url, err)
+			w.WriteHeader(gemini.STATUS_TEMPORARY_FAILURE, "Internal server error")
+		}
+	}()
+	handler := s.Handler
+	if handler == nil {
+		handler = HandlerFunc(NotFound)
+	}
+	handler.ServeGemini(w, r)
+}
+
+// servesHost checks if the server answers for host.
+func (s *Server) servesHost(host string) bool {
+	if len(s.Hosts) == 0 {
+		return true
+	}
+	for _, h := range s.Hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// readRequest reads and parses a request line.
+func readRequest(reader *bufio.Reader) (*url.URL, error) {
+	var line []byte
+	for {
+		c, err := reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("incomplete request: %w", err)
+		}
+		if c == '\n' {
+			break
+		}
+		line = append(line, c)
+		if len(line) > gemini.MAX_REQUEST_LENGTH+1 {
+			return nil, errors.New("request is longer than 1024 bytes")
+		}
+	}
+	if len(line) == 0 || line[len(line)-1] != '\r' {
+		return nil, errors.New("request must end with CRLF")
+	}
+	line = line[:len(line)-1]
+	if len(line) > gemini.MAX_REQUEST_LENGTH {
+		return nil, errors.New("request is longer than 1024 bytes")
+	}
+
+	u, err := url.Parse(string(line))
+	if err != nil {
+		return nil, errors.New("invalid url")
+	}
+	if !u.IsAbs() || len(u.Host) == 0 {
+		return nil, errors.New("request must be an absolute url")
+	}
+	if u.User != nil {
+		return nil, errors.New("request must not contain userinfo")
+	}
+	if len(u.Fragment) > 0 || strings.HasSuffix(string(line), "#") {
+		return nil, errors.New("request must not contain a fragment")
+	}
+	return u, nil
+}
+
+// response is the ResponseWriter for a connection.
+type response struct {
+	writer      *bufio.Writer
+	wroteHeader bool
+	status      gemini.Status
+}
+
+func (w *response) WriteHeader(status gemini.Status, meta string) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	meta = strings.NewReplacer("\r", " ", "\n", " ").Replace(meta)
+	if len(meta) > gemini.MAX_META_LENGTH {
+		// Cut at a rune boundary so the meta stays valid UTF-8
+		end := gemini.MAX_META_LENGTH
+		for end > 0 && !utf8.RuneStart(meta[end]) {
+			end--
+		}
+		meta = meta[:end]
+	}
+	fmt.Fprintf(w.writer, "%d %s\r\n", int(status), meta)
+}
+
+func (w *response) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(gemini.STATUS_SUCCESS, "text/gemini")
+	}
+	if !w.status.IsSuccess() {
+		return 0, ErrBodyNotAllowed
+	}
+	return w.writer.Write(p)
+}
+
+func (w *response) Flush() error {
+	return w.writer.Flush()
+}
+
+// finish writes a header if the handler did not and flushes the response.
+func (w *response) finish() {
+	if !w.wroteHeader {
+		w.WriteHeader(gemini.STATUS_SUCCESS, "text/gemini")
+	}
+	w.writer.Flush()
+}
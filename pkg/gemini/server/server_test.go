@@ -0,0 +1,288 @@
+package server_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jasmaa/hikawa/internal/testcert"
+	"github.com/jasmaa/hikawa/pkg/gemini"
+	"github.com/jasmaa/hikawa/pkg/gemini/server"
+	"github.com/stretchr/testify/assert"
+)
+
+// startServer serves handler on a local port. It returns the base url, the
+// server and a channel that gets the error Serve returns.
+func startServer(t *testing.T, handler server.Handler) (string, *server.Server, <-chan error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &server.Server{
+		Handler:   handler,
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{testcert.New(t, time.Now().Add(time.Hour))}},
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(l)
+	}()
+	t.Cleanup(func() { srv.Close() })
+	return "gemini://" + l.Addr().String(), srv, serveErr
+}
+
+// rawRequest sends a raw request line and reads the whole response.
+func rawRequest(t *testing.T, base string, line string) string {
+	conn, err := tls.Dial("tcp", strings.TrimPrefix(base, "gemini://"), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Error(err)
+		return ""
+	}
+	defer conn.Close()
+	conn.Write([]byte(line))
+	data, _ := io.ReadAll(conn)
+	return string(data)
+}
+
+// TestServerServes tests serving a page to the client.
+func TestServerServes(t *testing.T) {
+	base, _, _ := startServer(t, server.HandlerFunc(func(w server.ResponseWriter, r *server.Request) {
+		w.WriteHeader(gemini.STATUS_SUCCESS, "text/gemini; lang=en")
+		w.Write([]byte("# " + r.Url.Path))
+	}))
+	client := gemini.MakeClient()
+	resp, err := client.NavigatePage(base + "/hello")
+	if assert.Nil(t, err) {
+		data, _ := resp.Response.ReadAll(0)
+		assert.Equal(t, "# /hello", string(data))
+		assert.Equal(t, "en", resp.Lang)
+	}
+}
+
+// TestServerDefaultHeader tests sending 20 text/gemini when the handler writes no header.
+func TestServerDefaultHeader(t *testing.T) {
+	base, _, _ := startServer(t, server.HandlerFunc(func(w server.ResponseWriter, r *server.Request) {
+		w.Write([]byte("body"))
+	}))
+	assert.Equal(t, "20 text/gemini\r\nbody", rawRequest(t, base, base+"/\r\n"))
+}
+
+// TestServerBodyNotAllowed tests refusing a body after a failure header.
+func TestServerBodyNotAllowed(t *testing.T) {
+	writeErr := make(chan error, 1)
+	base, _, _ := startServer(t, server.HandlerFunc(func(w server.ResponseWriter, r *server.Request) {
+		w.WriteHeader(gemini.STATUS_NOT_FOUND, "gone\r\nfishing")
+		_, err := w.Write([]byte("body"))
+		writeErr <- err
+	}))
+	assert.Equal(t, "51 gone  fishing\r\n", rawRequest(t, base, base+"/\r\n"))
+	assert.True(t, errors.Is(<-writeErr, server.ErrBodyNotAllowed))
+}
+
+// TestServerBadRequests tests answering malformed requests with 59 BAD REQUEST.
+func TestServerBadRequests(t *testing.T) {
+	base, _, _ := startServer(t, server.HandlerFunc(func(w server.ResponseWriter, r *server.Request) {
+		w.Write([]byte("ok"))
+	}))
+	for _, line := range []string{
+		base + "/" + strings.Repeat("a", gemini.MAX_REQUEST_LENGTH) + "\r\n",
+		"/relative\r\n",
+		"gemini://user@" + strings.TrimPrefix(base, "gemini://") + "/\r\n",
+		base + "/#fragment\r\n",
+		base + "/\n",
+	} {
+		resp := rawRequest(t, base, line)
+		assert.True(t, strings.HasPrefix(resp, "59 "), resp)
+	}
+}
+
+// TestServerLongMeta tests cutting meta so the client accepts the header.
+func TestServerLongMeta(t *testing.T) {
+	base, _, _ := startServer(t, server.HandlerFunc(func(w server.ResponseWriter, r *server.Request) {
+		w.WriteHeader(gemini.STATUS_NOT_FOUND, "a"+strings.Repeat("é", gemini.MAX_META_LENGTH))
+	}))
+	client := gemini.MakeClient()
+	_, err := client.NavigatePage(base + "/")
+	var statusErr *gemini.StatusError
+	if assert.True(t, errors.As(err, &statusErr)) {
+		assert.Equal(t, "a"+strings.Repeat("é", (gemini.MAX_META_LENGTH-1)/2), statusErr.Meta)
+	}
+}
+
+// TestServerProxyRequests tests refusing requests for other schemes and hosts.
+func TestServerProxyRequests(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &server.Server{
+		Handler: server.HandlerFunc(func(w server.ResponseWriter, r *server.Request) {
+			w.Write([]byte("ok"))
+		}),
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{testcert.New(t, time.Now().Add(time.Hour))}},
+		Hosts:     []string{"Example.org"},
+	}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+
+	base := "gemini://" + l.Addr().String()
+	assert.Equal(t, "20 text/gemini\r\nok", rawRequest(t, base, "gemini://example.ORG/\r\n"))
+	for _, line := range []string{
+		"https://example.org/\r\n",
+		"gopher://example.org/\r\n",
+		"gemini://example.com/\r\n",
+		base + "/\r\n",
+	} {
+		assert.Equal(t, "53 Proxy request refused\r\n", rawRequest(t, base, line), line)
+	}
+
+	base, _, _ = startServer(t, server.HandlerFunc(func(w server.ResponseWriter, r *server.Request) {
+		w.Write([]byte("ok"))
+	}))
+	assert.Equal(t, "20 text/gemini\r\nok", rawRequest(t, base, "gemini://example.com/\r\n"))
+	assert.Equal(t, "53 Proxy request refused\r\n", rawRequest(t, base, "https://example.com/\r\n"))
+}
+
+// TestServerCertificate tests exposing the client certificate to handlers.
+func TestServerCertificate(t *testing.T) {
+	base, _, _ := startServer(t, server.HandlerFunc(func(w server.ResponseWriter, r *server.Request) {
+		if r.Certificate == nil {
+			w.WriteHeader(gemini.STATUS_CLIENT_CERTIFICATE_REQUIRED, "Identity needed")
+			return
+		}
+		w.Write([]byte(gemini.Fingerprint(r.Certificate)))
+	}))
+	client := gemini.MakeClient()
+	_, err := client.NavigatePage(base + "/")
+	var statusErr *gemini.StatusError
+	if assert.True(t, errors.As(err, &statusErr)) {
+		assert.Equal(t, gemini.STATUS_CLIENT_CERTIFICATE_REQUIRED, statusErr.Status)
+	}
+
+	identity, err := gemini.NewIdentity("tester", gemini.KEY_ALGORITHM_ED25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Identities.Add(identity)
+	client.Identities.Bind("tester", base+"/")
+	resp, err := client.NavigatePage(base + "/")
+	if assert.Nil(t, err) {
+		data, _ := resp.Response.ReadAll(0)
+		assert.Equal(t, identity.Fingerprint(), string(data))
+	}
+}
+
+// logWriter sends each log line on a channel.
+type logWriter chan string
+
+func (l logWriter) Write(p []byte) (int, error) {
+	l <- string(p)
+	return len(p), nil
+}
+
+// TestServerPanic tests answering with 40 when a handler panics.
+func TestServerPanic(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := make(logWriter, 1)
+	srv := &server.Server{
+		Handler: server.HandlerFunc(func(w server.ResponseWriter, r *server.Request) {
+			panic("broken")
+		}),
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{testcert.New(t, time.Now().Add(time.Hour))}},
+		ErrorLog:  log.New(logs, "", 0),
+	}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+
+	base := "gemini://" + l.Addr().String()
+	assert.Equal(t, "40 Internal server error\r\n", rawRequest(t, base, base+"/\r\n"))
+	assert.Contains(t, <-logs, "broken")
+}
+
+// TestServerShutdown tests finishing active requests before shutting down.
+func TestServerShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	base, srv, serveErr := startServer(t, server.HandlerFunc(func(w server.ResponseWriter, r *server.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("finished"))
+	}))
+
+	response := make(chan string, 1)
+	go func() {
+		response <- rawRequest(t, base, base+"/\r\n")
+	}()
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- srv.Shutdown(context.Background())
+	}()
+	assert.True(t, errors.Is(<-serveErr, server.ErrServerClosed))
+	_, err := tls.Dial("tcp", strings.TrimPrefix(base, "gemini://"), &tls.Config{InsecureSkipVerify: true})
+	assert.NotNil(t, err)
+
+	close(release)
+	assert.Equal(t, "20 text/gemini\r\nfinished", <-response)
+	assert.Nil(t, <-shutdownErr)
+}
+
+// TestServerShutdownTimeout tests closing requests still active when the shutdown context is done.
+func TestServerShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	base, srv, _ := startServer(t, server.HandlerFunc(func(w server.ResponseWriter, r *server.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(cancelled)
+	}))
+	go rawRequest(t, base, base+"/\r\n")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.True(t, errors.Is(srv.Shutdown(ctx), context.DeadlineExceeded))
+	<-cancelled
+}
+
+// TestServerNoCertificate tests refusing to serve without a certificate.
+func TestServerNoCertificate(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &server.Server{}
+	assert.NotNil(t, srv.Serve(l))
+}
+
+// TestServerStreams tests flushing a body while the handler is still writing.
+func TestServerStreams(t *testing.T) {
+	release := make(chan struct{})
+	base, _, _ := startServer(t, server.HandlerFunc(func(w server.ResponseWriter, r *server.Request) {
+		w.Write([]byte("first\n"))
+		w.Flush()
+		<-release
+		w.Write([]byte("second\n"))
+	}))
+	client := gemini.MakeClient()
+	resp, err := client.NavigatePage(base + "/")
+	if assert.Nil(t, err) {
+		reader := bufio.NewReader(resp.Response.Body)
+		line, _ := reader.ReadString('\n')
+		assert.Equal(t, "first\n", line)
+		close(release)
+		line, _ = reader.ReadString('\n')
+		assert.Equal(t, "second\n", line)
+		resp.Response.Body.Close()
+	}
+}
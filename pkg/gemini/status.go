@@ -1,65 +1,219 @@
 package gemini
 
+import "fmt"
+
+// Status is a Gemini response status code.
+type Status int
+
+const (
+	STATUS_INPUT                       Status = 10
+	STATUS_SENSITIVE_INPUT             Status = 11
+	STATUS_SUCCESS                     Status = 20
+	STATUS_REDIRECT_TEMPORARY          Status = 30
+	STATUS_REDIRECT_PERMANENT          Status = 31
+	STATUS_TEMPORARY_FAILURE           Status = 40
+	STATUS_SERVER_UNAVAILABLE          Status = 41
+	STATUS_CGI_ERROR                   Status = 42
+	STATUS_PROXY_ERROR                 Status = 43
+	STATUS_SLOW_DOWN                   Status = 44
+	STATUS_PERMANENT_FAILURE           Status = 50
+	STATUS_NOT_FOUND                   Status = 51
+	STATUS_GONE                        Status = 52
+	STATUS_PROXY_REQUEST_REFUSED       Status = 53
+	STATUS_BAD_REQUEST                 Status = 59
+	STATUS_CLIENT_CERTIFICATE_REQUIRED Status = 60
+	STATUS_CERTIFICATE_NOT_AUTHORISED  Status = 61
+	STATUS_CERTIFICATE_NOT_VALID       Status = 62
+)
+
+// StatusClass is the first digit of a status.
+type StatusClass int
+
 const (
-	STATUS_INPUT                       = 10
-	STATUS_SENSITIVE_INPUT             = 11
-	STATUS_SUCCESS                     = 20
-	STATUS_REDIRECT_TEMPORARY          = 30
-	STATUS_REDIRECT_PERMANENT          = 31
-	STATUS_TEMPORARY_FAILURE           = 40
-	STATUS_SERVER_UNAVAILABLE          = 41
-	STATUS_CGI_ERROR                   = 42
-	STATUS_PROXY_ERROR                 = 43
-	STATUS_SLOW_DOWN                   = 44
-	STATUS_PERMANENT_FAILURE           = 50
-	STATUS_NOT_FOUND                   = 51
-	STATUS_GONE                        = 52
-	STATUS_PROXY_REQUEST_REFUSED       = 53
-	STATUS_BAD_REQUEST                 = 59
-	STATUS_CLIENT_CERTIFICATE_REQUIRED = 60
-	STATUS_CERTIFICATE_NOT_AUTHORISED  = 61
-	STATUS_CERTIFICATE_NOT_VALID       = 62
+	STATUS_CLASS_INPUT              StatusClass = 1
+	STATUS_CLASS_SUCCESS            StatusClass = 2
+	STATUS_CLASS_REDIRECT           StatusClass = 3
+	STATUS_CLASS_TEMPORARY_FAILURE  StatusClass = 4
+	STATUS_CLASS_PERMANENT_FAILURE  StatusClass = 5
+	STATUS_CLASS_CLIENT_CERTIFICATE StatusClass = 6
 )
 
-func statusCodeToMessage(code int) string {
-	switch code {
-	case 10:
+// String gets the name of the class.
+func (c StatusClass) String() string {
+	switch c {
+	case STATUS_CLASS_INPUT:
 		return "INPUT"
-	case 11:
+	case STATUS_CLASS_SUCCESS:
+		return "SUCCESS"
+	case STATUS_CLASS_REDIRECT:
+		return "REDIRECT"
+	case STATUS_CLASS_TEMPORARY_FAILURE:
+		return "TEMPORARY FAILURE"
+	case STATUS_CLASS_PERMANENT_FAILURE:
+		return "PERMANENT FAILURE"
+	case STATUS_CLASS_CLIENT_CERTIFICATE:
+		return "CLIENT CERTIFICATE REQUIRED"
+	default:
+		return "Invalid class"
+	}
+}
+
+// Class gets the class of the status.
+func (s Status) Class() StatusClass {
+	return StatusClass(s / 10)
+}
+
+// IsValid checks if the status is in a known class.
+func (s Status) IsValid() bool {
+	return s >= 10 && s <= 69
+}
+
+// IsInput checks for a 1X status.
+func (s Status) IsInput() bool {
+	return s.Class() == STATUS_CLASS_INPUT
+}
+
+// IsSuccess checks for a 2X status.
+func (s Status) IsSuccess() bool {
+	return s.Class() == STATUS_CLASS_SUCCESS
+}
+
+// IsRedirect checks for a 3X status.
+func (s Status) IsRedirect() bool {
+	return s.Class() == STATUS_CLASS_REDIRECT
+}
+
+// IsTemporaryFailure checks for a 4X status.
+func (s Status) IsTemporaryFailure() bool {
+	return s.Class() == STATUS_CLASS_TEMPORARY_FAILURE
+}
+
+// IsPermanentFailure checks for a 5X status.
+func (s Status) IsPermanentFailure() bool {
+	return s.Class() == STATUS_CLASS_PERMANENT_FAILURE
+}
+
+// IsClientCertificate checks for a 6X status.
+func (s Status) IsClientCertificate() bool {
+	return s.Class() == STATUS_CLASS_CLIENT_CERTIFICATE
+}
+
+// IsFailure checks for a 4X, 5X or 6X status.
+func (s Status) IsFailure() bool {
+	return s.IsTemporaryFailure() || s.IsPermanentFailure() || s.IsClientCertificate()
+}
+
+// String gets the name of the status. Unknown statuses in a valid class are
+// named after the class.
+func (s Status) String() string {
+	switch s {
+	case STATUS_INPUT:
+		return "INPUT"
+	case STATUS_SENSITIVE_INPUT:
 		return "SENSITIVE INPUT"
-	case 20:
+	case STATUS_SUCCESS:
 		return "SUCCESS"
-	case 30:
+	case STATUS_REDIRECT_TEMPORARY:
 		return "REDIRECT - TEMPORARY"
-	case 31:
+	case STATUS_REDIRECT_PERMANENT:
 		return "REDIRECT - PERMANENT"
-	case 40:
+	case STATUS_TEMPORARY_FAILURE:
 		return "TEMPORARY FAILURE"
-	case 41:
+	case STATUS_SERVER_UNAVAILABLE:
 		return "SERVER UNAVAILABLE"
-	case 42:
+	case STATUS_CGI_ERROR:
 		return "CGI ERROR"
-	case 43:
+	case STATUS_PROXY_ERROR:
 		return "PROXY ERROR"
-	case 44:
+	case STATUS_SLOW_DOWN:
 		return "SLOW DOWN"
-	case 50:
+	case STATUS_PERMANENT_FAILURE:
 		return "PERMANENT FAILURE"
-	case 51:
+	case STATUS_NOT_FOUND:
 		return "NOT FOUND"
-	case 52:
+	case STATUS_GONE:
 		return "GONE"
-	case 53:
+	case STATUS_PROXY_REQUEST_REFUSED:
 		return "PROXY REQUEST REFUSED"
-	case 59:
+	case STATUS_BAD_REQUEST:
 		return "BAD REQUEST"
-	case 60:
+	case STATUS_CLIENT_CERTIFICATE_REQUIRED:
 		return "CLIENT CERTIFICATE REQUIRED"
-	case 61:
+	case STATUS_CERTIFICATE_NOT_AUTHORISED:
 		return "CERTIFICATE NOT AUTHORISED"
-	case 62:
+	case STATUS_CERTIFICATE_NOT_VALID:
 		return "CERTIFICATE NOT VALID"
 	default:
+		if s.IsValid() {
+			return s.Class().String()
+		}
 		return "Invalid code"
 	}
 }
+
+// Description gets a human explanation of the status.
+func (s Status) Description() string {
+	switch s {
+	case STATUS_INPUT:
+		return "The page is asking for input."
+	case STATUS_SENSITIVE_INPUT:
+		return "The page is asking for sensitive input, such as a password."
+	case STATUS_SUCCESS:
+		return "The page was found."
+	case STATUS_REDIRECT_TEMPORARY:
+		return "The page has moved for now."
+	case STATUS_REDIRECT_PERMANENT:
+		return "The page has moved for good."
+	case STATUS_TEMPORARY_FAILURE:
+		return "The server could not handle the request right now. Try again later."
+	case STATUS_SERVER_UNAVAILABLE:
+		return "The server is down for maintenance or overloaded. Try again later."
+	case STATUS_CGI_ERROR:
+		return "A script on the server failed or timed out while generating the page."
+	case STATUS_PROXY_ERROR:
+		return "The server could not reach the host it was proxying for."
+	case STATUS_SLOW_DOWN:
+		return "Too many requests were sent to the server. Wait before trying again."
+	case STATUS_PERMANENT_FAILURE:
+		return "The server will not handle this request. Trying again will not help."
+	case STATUS_NOT_FOUND:
+		return "The page does not exist on this server."
+	case STATUS_GONE:
+		return "The page used to exist but has been removed for good."
+	case STATUS_PROXY_REQUEST_REFUSED:
+		return "The server does not serve this host or scheme and will not proxy for it."
+	case STATUS_BAD_REQUEST:
+		return "The server could not understand the request."
+	case STATUS_CLIENT_CERTIFICATE_REQUIRED:
+		return "The page needs a client certificate. Create or choose an identity to continue."
+	case STATUS_CERTIFICATE_NOT_AUTHORISED:
+		return "Your identity is not allowed to see this page."
+	case STATUS_CERTIFICATE_NOT_VALID:
+		return "Your identity was rejected, for example because it has expired."
+	}
+	switch s.Class() {
+	case STATUS_CLASS_TEMPORARY_FAILURE:
+		return "The server could not handle the request right now. Try again later."
+	case STATUS_CLASS_PERMANENT_FAILURE:
+		return "The server will not handle this request."
+	case STATUS_CLASS_CLIENT_CERTIFICATE:
+		return "The page needs a different client certificate."
+	default:
+		return "The server returned an unknown status."
+	}
+}
+
+// StatusError is returned by the client for 4X, 5X and 6X responses.
+type StatusError struct {
+	Status Status
+	Meta   string
+	// Url is the url that returned the status.
+	Url string
+}
+
+func (e *StatusError) Error() string {
+	if len(e.Meta) == 0 {
+		return fmt.Sprintf("%d %s", int(e.Status), e.Status)
+	}
+	return fmt.Sprintf("%d %s: %s", int(e.Status), e.Status, e.Meta)
+}
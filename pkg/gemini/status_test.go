@@ -0,0 +1,56 @@
+package gemini_test
+
+import (
+	"testing"
+
+	"github.com/jasmaa/hikawa/pkg/gemini"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStatusClass tests classifying statuses.
+func TestStatusClass(t *testing.T) {
+	assert.Equal(t, gemini.STATUS_CLASS_INPUT, gemini.STATUS_SENSITIVE_INPUT.Class())
+	assert.Equal(t, gemini.STATUS_CLASS_SUCCESS, gemini.STATUS_SUCCESS.Class())
+	assert.Equal(t, gemini.STATUS_CLASS_REDIRECT, gemini.STATUS_REDIRECT_PERMANENT.Class())
+	assert.Equal(t, gemini.STATUS_CLASS_TEMPORARY_FAILURE, gemini.STATUS_SLOW_DOWN.Class())
+	assert.Equal(t, gemini.STATUS_CLASS_PERMANENT_FAILURE, gemini.STATUS_NOT_FOUND.Class())
+	assert.Equal(t, gemini.STATUS_CLASS_CLIENT_CERTIFICATE, gemini.STATUS_CERTIFICATE_NOT_VALID.Class())
+}
+
+// TestStatusPredicates tests the IsX helpers.
+func TestStatusPredicates(t *testing.T) {
+	assert.True(t, gemini.STATUS_INPUT.IsInput())
+	assert.True(t, gemini.STATUS_SUCCESS.IsSuccess())
+	assert.True(t, gemini.STATUS_REDIRECT_TEMPORARY.IsRedirect())
+	assert.True(t, gemini.STATUS_CGI_ERROR.IsTemporaryFailure())
+	assert.True(t, gemini.STATUS_GONE.IsPermanentFailure())
+	assert.True(t, gemini.STATUS_CLIENT_CERTIFICATE_REQUIRED.IsClientCertificate())
+	assert.True(t, gemini.STATUS_BAD_REQUEST.IsFailure())
+	assert.False(t, gemini.STATUS_SUCCESS.IsFailure())
+	assert.False(t, gemini.Status(7).IsValid())
+	assert.False(t, gemini.Status(70).IsValid())
+}
+
+// TestStatusString tests naming known and unknown statuses.
+func TestStatusString(t *testing.T) {
+	assert.Equal(t, "NOT FOUND", gemini.STATUS_NOT_FOUND.String())
+	assert.Equal(t, "REDIRECT - PERMANENT", gemini.STATUS_REDIRECT_PERMANENT.String())
+	assert.Equal(t, "TEMPORARY FAILURE", gemini.Status(45).String())
+	assert.Equal(t, "Invalid code", gemini.Status(99).String())
+}
+
+// TestStatusDescription tests explaining every failure status.
+func TestStatusDescription(t *testing.T) {
+	for status := gemini.Status(40); status < 70; status++ {
+		assert.NotEmpty(t, status.Description())
+	}
+	assert.NotEqual(t, gemini.STATUS_NOT_FOUND.Description(), gemini.STATUS_GONE.Description())
+}
+
+// TestStatusError tests formatting a status error.
+func TestStatusError(t *testing.T) {
+	err := &gemini.StatusError{Status: gemini.STATUS_NOT_FOUND, Meta: "no such page"}
+	assert.Equal(t, "51 NOT FOUND: no such page", err.Error())
+	err = &gemini.StatusError{Status: gemini.STATUS_GONE}
+	assert.Equal(t, "52 GONE", err.Error())
+}
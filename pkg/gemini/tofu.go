@@ -0,0 +1,214 @@
+package gemini
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KnownHost is a certificate pinned for a host.
+type KnownHost struct {
+	Fingerprint string
+	Expiry      time.Time
+}
+
+// KnownHosts stores certificates pinned on first use, keyed by host:port.
+type KnownHosts interface {
+	// Lookup gets the pin for a host:port.
+	Lookup(hostport string) (KnownHost, bool)
+	// Trust pins a certificate for a host:port, replacing any existing pin.
+	Trust(hostport string, host KnownHost) error
+}
+
+// UnknownHostError is returned when a host has no pinned certificate.
+type UnknownHostError struct {
+	Host        string
+	Certificate KnownHost
+}
+
+func (e *UnknownHostError) Error() string {
+	return fmt.Sprintf("unknown host %s with certificate %s", e.Host, e.Certificate.Fingerprint)
+}
+
+// CertificateChangedError is returned when a host presents a certificate
+// that does not match its unexpired pin.
+type CertificateChangedError struct {
+	Host        string
+	Pinned      KnownHost
+	Certificate KnownHost
+}
+
+func (e *CertificateChangedError) Error() string {
+	return fmt.Sprintf("certificate for %s changed from %s to %s", e.Host, e.Pinned.Fingerprint, e.Certificate.Fingerprint)
+}
+
+// ExpiredPinError is returned when a host presents a new certificate after
+// its pinned certificate has expired.
+type ExpiredPinError struct {
+	Host        string
+	Pinned      KnownHost
+	Certificate KnownHost
+}
+
+func (e *ExpiredPinError) Error() string {
+	return fmt.Sprintf("pinned certificate for %s expired on %s", e.Host, e.Pinned.Expiry.Format(time.RFC3339))
+}
+
+// Fingerprint gets the SHA-256 fingerprint of a certificate.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyKnownHost checks a server certificate against the pin for hostport.
+// New hosts are pinned when trustNewHosts is set.
+func verifyKnownHost(knownHosts KnownHosts, hostport string, rawCerts [][]byte, trustNewHosts bool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("no server certificate")
+	}
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return err
+	}
+	presented := KnownHost{
+		Fingerprint: Fingerprint(cert),
+		Expiry:      cert.NotAfter,
+	}
+
+	pinned, ok := knownHosts.Lookup(hostport)
+	if !ok {
+		if trustNewHosts {
+			return knownHosts.Trust(hostport, presented)
+		}
+		return &UnknownHostError{Host: hostport, Certificate: presented}
+	}
+	if pinned.Fingerprint == presented.Fingerprint {
+		return nil
+	}
+	if time.Now().After(pinned.Expiry) {
+		return &ExpiredPinError{Host: hostport, Pinned: pinned, Certificate: presented}
+	}
+	return &CertificateChangedError{Host: hostport, Pinned: pinned, Certificate: presented}
+}
+
+// MemoryKnownHosts is an in-memory KnownHosts.
+type MemoryKnownHosts struct {
+	mu    sync.RWMutex
+	hosts map[string]KnownHost
+}
+
+// NewMemoryKnownHosts creates an empty MemoryKnownHosts.
+func NewMemoryKnownHosts() *MemoryKnownHosts {
+	return &MemoryKnownHosts{
+		hosts: make(map[string]KnownHost),
+	}
+}
+
+// Lookup gets the pin for a host:port.
+func (k *MemoryKnownHosts) Lookup(hostport string) (KnownHost, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	host, ok := k.hosts[hostport]
+	return host, ok
+}
+
+// Trust pins a certificate for a host:port.
+func (k *MemoryKnownHosts) Trust(hostport string, host KnownHost) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.hosts[hostport] = host
+	return nil
+}
+
+// FileKnownHosts is a KnownHosts persisted to a file.
+// Each line of the file is `host:port fingerprint expiry`, with expiry in Unix seconds.
+type FileKnownHosts struct {
+	path   string
+	memory *MemoryKnownHosts
+}
+
+// OpenFileKnownHosts loads known hosts from path. A missing file is treated as empty.
+func OpenFileKnownHosts(path string) (*FileKnownHosts, error) {
+	k := &FileKnownHosts{
+		path:   path,
+		memory: NewMemoryKnownHosts(),
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed known host: %q", scanner.Text())
+		}
+		expiry, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed known host expiry: %q", scanner.Text())
+		}
+		k.memory.hosts[fields[0]] = KnownHost{
+			Fingerprint: fields[1],
+			Expiry:      time.Unix(expiry, 0),
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Lookup gets the pin for a host:port.
+func (k *FileKnownHosts) Lookup(hostport string) (KnownHost, bool) {
+	return k.memory.Lookup(hostport)
+}
+
+// Trust pins a certificate for a host:port and saves the file.
+func (k *FileKnownHosts) Trust(hostport string, host KnownHost) error {
+	k.memory.mu.Lock()
+	defer k.memory.mu.Unlock()
+	k.memory.hosts[hostport] = host
+	return k.save()
+}
+
+// save writes all pins to disk. Caller must hold the lock.
+func (k *FileKnownHosts) save() error {
+	hostports := make([]string, 0, len(k.memory.hosts))
+	for hostport := range k.memory.hosts {
+		hostports = append(hostports, hostport)
+	}
+	sort.Strings(hostports)
+
+	var b strings.Builder
+	for _, hostport := range hostports {
+		host := k.memory.hosts[hostport]
+		fmt.Fprintf(&b, "%s %s %d\n", hostport, host.Fingerprint, host.Expiry.Unix())
+	}
+
+	if err := os.MkdirAll(filepath.Dir(k.path), 0o700); err != nil {
+		return err
+	}
+	tmp := k.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, k.path)
+}
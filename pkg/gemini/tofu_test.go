@@ -0,0 +1,128 @@
+package gemini_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jasmaa/hikawa/internal/testcert"
+	"github.com/jasmaa/hikawa/internal/testserver"
+	"github.com/jasmaa/hikawa/pkg/gemini"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveOnce serves a Gemini success response with cert and returns the url.
+func serveOnce(t *testing.T, cert tls.Certificate) string {
+	return "gemini://" + testserver.TLS(t, cert, func(conn *tls.Conn) {
+		readRequest(bufio.NewReader(conn))
+		conn.Write([]byte("20 text/gemini\r\nhello"))
+	}) + "/"
+}
+
+func fingerprintOf(cert tls.Certificate) string {
+	parsed, _ := x509.ParseCertificate(cert.Certificate[0])
+	return gemini.Fingerprint(parsed)
+}
+
+// TestTofuPinsNewHost tests pinning a certificate on first use.
+func TestTofuPinsNewHost(t *testing.T) {
+	cert := testcert.New(t, time.Now().Add(time.Hour))
+	rawurl := serveOnce(t, cert)
+	client := gemini.MakeClient()
+	resp, err := client.Request(rawurl)
+	if assert.Nil(t, err) {
+		data, _ := resp.ReadAll(0)
+		assert.Equal(t, "hello", string(data))
+	}
+	host, ok := client.KnownHosts.Lookup(rawurl[len("gemini://") : len(rawurl)-1])
+	if assert.True(t, ok) {
+		assert.Equal(t, fingerprintOf(cert), host.Fingerprint)
+	}
+}
+
+// TestTofuUnknownHost tests erroring on a new host when new hosts are not trusted.
+func TestTofuUnknownHost(t *testing.T) {
+	rawurl := serveOnce(t, testcert.New(t, time.Now().Add(time.Hour)))
+	client := gemini.MakeClient()
+	client.TrustNewHosts = false
+	_, err := client.Request(rawurl)
+	var unknownErr *gemini.UnknownHostError
+	assert.True(t, errors.As(err, &unknownErr))
+}
+
+// TestTofuTrustUnknownHost tests navigating to a new host after trusting
+// the certificate from its UnknownHostError.
+func TestTofuTrustUnknownHost(t *testing.T) {
+	cert := testcert.New(t, time.Now().Add(time.Hour))
+	rawurl := serveOnce(t, cert)
+	client := gemini.MakeClient()
+	client.TrustNewHosts = false
+	_, err := client.NavigatePage(rawurl)
+	var unknownErr *gemini.UnknownHostError
+	if !assert.True(t, errors.As(err, &unknownErr)) {
+		return
+	}
+	assert.Equal(t, fingerprintOf(cert), unknownErr.Certificate.Fingerprint)
+
+	assert.Nil(t, client.KnownHosts.Trust(unknownErr.Host, unknownErr.Certificate))
+	resp, err := client.NavigatePage(rawurl)
+	if assert.Nil(t, err) {
+		data, _ := resp.Response.ReadAll(0)
+		assert.Equal(t, "hello", string(data))
+	}
+}
+
+// TestTofuCertificateChanged tests erroring when a pinned certificate changes.
+func TestTofuCertificateChanged(t *testing.T) {
+	cert := testcert.New(t, time.Now().Add(time.Hour))
+	rawurl := serveOnce(t, cert)
+	client := gemini.MakeClient()
+	client.KnownHosts.Trust(rawurl[len("gemini://"):len(rawurl)-1], gemini.KnownHost{
+		Fingerprint: "deadbeef",
+		Expiry:      time.Now().Add(time.Hour),
+	})
+	_, err := client.Request(rawurl)
+	var changedErr *gemini.CertificateChangedError
+	if assert.True(t, errors.As(err, &changedErr)) {
+		assert.Equal(t, fingerprintOf(cert), changedErr.Certificate.Fingerprint)
+	}
+}
+
+// TestTofuExpiredPin tests erroring when a new certificate replaces an expired pin.
+func TestTofuExpiredPin(t *testing.T) {
+	rawurl := serveOnce(t, testcert.New(t, time.Now().Add(time.Hour)))
+	client := gemini.MakeClient()
+	client.KnownHosts.Trust(rawurl[len("gemini://"):len(rawurl)-1], gemini.KnownHost{
+		Fingerprint: "deadbeef",
+		Expiry:      time.Now().Add(-time.Hour),
+	})
+	_, err := client.Request(rawurl)
+	var expiredErr *gemini.ExpiredPinError
+	assert.True(t, errors.As(err, &expiredErr))
+}
+
+// TestFileKnownHostsPersist tests reloading pins from disk.
+func TestFileKnownHostsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	knownHosts, err := gemini.OpenFileKnownHosts(path)
+	if !assert.Nil(t, err) {
+		return
+	}
+	expiry := time.Unix(1700000000, 0)
+	hostport := net.JoinHostPort("example.com", "1965")
+	assert.Nil(t, knownHosts.Trust(hostport, gemini.KnownHost{Fingerprint: "abc123", Expiry: expiry}))
+
+	reloaded, err := gemini.OpenFileKnownHosts(path)
+	if assert.Nil(t, err) {
+		host, ok := reloaded.Lookup(hostport)
+		if assert.True(t, ok) {
+			assert.Equal(t, "abc123", host.Fingerprint)
+			assert.True(t, expiry.Equal(host.Expiry))
+		}
+	}
+}
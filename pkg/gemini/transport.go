@@ -0,0 +1,181 @@
+package gemini
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// Dialer opens network connections for a TLSTransport.
+// *net.Dialer and the dialer from NewSOCKS5Dialer are Dialers.
+type Dialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// TransportRequest is a single request sent by a Transport.
+type TransportRequest struct {
+	// Url is sent as the request line.
+	Url *url.URL
+	// Addr is the host:port to connect to.
+	Addr string
+	// TLSConfig checks the server certificate and presents the client certificate.
+	TLSConfig *tls.Config
+	// Body is sent after the request line, for Titan uploads. It may be nil.
+	Body io.Reader
+}
+
+// Transport sends a single request and reads the response header, like
+// net/http's RoundTripper. It does not follow redirects or retry.
+// The returned Body must be closed to release the connection.
+type Transport interface {
+	RoundTrip(ctx context.Context, req *TransportRequest) (*Response, error)
+}
+
+// TLSTransport is a Transport that sends requests over TLS.
+type TLSTransport struct {
+	// Dialer opens connections. If nil, a net.Dialer is used.
+	Dialer Dialer
+	// DialTimeout limits connecting to the server. Zero means no limit.
+	DialTimeout time.Duration
+	// HandshakeTimeout limits the TLS handshake. Zero means no limit.
+	HandshakeTimeout time.Duration
+	// ReadTimeout limits the wait for each read of the response, so a stalled
+	// server is dropped while a slow stream is not. Zero means no limit.
+	ReadTimeout time.Duration
+}
+
+// DefaultTransport is the Transport used by clients without one.
+var DefaultTransport Transport = &TLSTransport{
+	DialTimeout:      10 * time.Second,
+	HandshakeTimeout: 10 * time.Second,
+	ReadTimeout:      30 * time.Second,
+}
+
+// RoundTrip sends req and reads the response header.
+// The connection is closed and ctx.Err() returned when ctx is done.
+func (t *TLSTransport) RoundTrip(ctx context.Context, req *TransportRequest) (*Response, error) {
+	var dialer Dialer = &net.Dialer{}
+	if t.Dialer != nil {
+		dialer = t.Dialer
+	}
+	dialCtx, cancelDial := withTimeout(ctx, t.DialTimeout)
+	rawConn, err := dialer.DialContext(dialCtx, "tcp", req.Addr)
+	cancelDial()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	conn := tls.Client(rawConn, req.TLSConfig)
+	handshakeCtx, cancelHandshake := withTimeout(ctx, t.HandshakeTimeout)
+	err = conn.HandshakeContext(handshakeCtx)
+	cancelHandshake()
+	if err != nil {
+		rawConn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("tls handshake timed out: %w", err)
+		}
+		return nil, err
+	}
+
+	// Close the connection when ctx is done or the body is closed
+	done := make(chan struct{})
+	var closeOnce sync.Once
+	closeConn := func() error {
+		err := net.ErrClosed
+		closeOnce.Do(func() {
+			close(done)
+			err = conn.Close()
+		})
+		return err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	_, err = conn.Write([]byte(req.Url.String() + "\r\n"))
+	if err == nil && req.Body != nil {
+		_, err = io.Copy(conn, req.Body)
+	}
+	if err != nil {
+		closeConn()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	var readConn net.Conn = conn
+	if t.ReadTimeout > 0 {
+		readConn = &readTimeoutConn{Conn: conn, timeout: t.ReadTimeout}
+	}
+	resp, err := ReadResponse(readConn)
+	if err != nil {
+		closeConn()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	b := resp.Body.(*body)
+	b.ctx = ctx
+	b.close = closeConn
+	return resp, nil
+}
+
+// withTimeout derives a context with a timeout, or no timeout if timeout is zero.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// readTimeoutConn sets a read deadline before every read.
+type readTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *readTimeoutConn) Read(p []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
+
+// NewSOCKS5Dialer makes a Dialer that connects through the SOCKS5 proxy at
+// proxyAddr, such as Tor on 127.0.0.1:9050. Host names are resolved by the
+// proxy, so .onion capsules can be reached. username and password may be empty.
+func NewSOCKS5Dialer(proxyAddr, username, password string) (Dialer, error) {
+	var auth *proxy.Auth
+	if len(username) > 0 || len(password) > 0 {
+		auth = &proxy.Auth{User: username, Password: password}
+	}
+	dialer, err := proxy.SOCKS5("tcp", proxyAddr, auth, proxy.Direct)
+	if err != nil {
+		return nil, err
+	}
+	contextDialer, ok := dialer.(proxy.ContextDialer)
+	if !ok {
+		return nil, errors.New("socks5 dialer does not support contexts")
+	}
+	return contextDialer, nil
+}
@@ -0,0 +1,161 @@
+package gemini_test
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jasmaa/hikawa/internal/testserver"
+	"github.com/jasmaa/hikawa/pkg/gemini"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTransport answers every request with the same response.
+type fakeTransport struct {
+	requests []*gemini.TransportRequest
+	rawresp  string
+}
+
+func (f *fakeTransport) RoundTrip(ctx context.Context, req *gemini.TransportRequest) (*gemini.Response, error) {
+	f.requests = append(f.requests, req)
+	return gemini.ReadResponse(strings.NewReader(f.rawresp))
+}
+
+// redirectDialer dials addr whatever address is asked for.
+type redirectDialer struct {
+	addr  string
+	asked []string
+}
+
+func (d *redirectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	d.asked = append(d.asked, addr)
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, network, d.addr)
+}
+
+// TestClientTransport tests sending requests through a custom Transport.
+func TestClientTransport(t *testing.T) {
+	transport := &fakeTransport{rawresp: "20 text/gemini\r\nhello"}
+	client := gemini.MakeClient()
+	client.Transport = transport
+	resp, err := client.NavigatePage("gemini://example.org/page")
+	if assert.Nil(t, err) {
+		data, _ := resp.Response.ReadAll(0)
+		assert.Equal(t, "hello", string(data))
+	}
+	if assert.Len(t, transport.requests, 1) {
+		assert.Equal(t, "gemini://example.org/page", transport.requests[0].Url.String())
+		assert.Equal(t, "example.org:1965", transport.requests[0].Addr)
+		assert.Equal(t, "example.org", transport.requests[0].TLSConfig.ServerName)
+	}
+}
+
+// TestTLSTransportDialer tests connecting with a custom Dialer.
+func TestTLSTransportDialer(t *testing.T) {
+	base := serveFunc(t, func(requestUrl string) string {
+		return "20 text/gemini\r\n" + requestUrl
+	})
+	dialer := &redirectDialer{addr: strings.TrimPrefix(base, "gemini://")}
+	client := gemini.MakeClient()
+	client.Transport = &gemini.TLSTransport{Dialer: dialer}
+	resp, err := client.NavigatePage("gemini://capsule.example/")
+	if assert.Nil(t, err) {
+		data, _ := resp.Response.ReadAll(0)
+		assert.Equal(t, "gemini://capsule.example/", string(data))
+	}
+	assert.Equal(t, []string{"capsule.example:1965"}, dialer.asked)
+}
+
+// TestTLSTransportReadTimeout tests dropping a server that stops sending.
+func TestTLSTransportReadTimeout(t *testing.T) {
+	client := gemini.MakeClient()
+	client.Timeout = 0
+	client.MaxRetries = 0
+	client.Transport = &gemini.TLSTransport{ReadTimeout: 100 * time.Millisecond}
+	_, err := client.NavigatePage(serveStalled(t))
+	var netErr net.Error
+	if assert.ErrorAs(t, err, &netErr) {
+		assert.True(t, netErr.Timeout())
+	}
+}
+
+// TestTLSTransportHandshakeTimeout tests giving up on a server that never handshakes.
+func TestTLSTransportHandshakeTimeout(t *testing.T) {
+	done := make(chan struct{})
+	addr := testserver.TCP(t, func(conn net.Conn) {
+		// Hold connections open without handshaking until the test ends
+		<-done
+	})
+	t.Cleanup(func() { close(done) })
+
+	client := gemini.MakeClient()
+	client.Timeout = 0
+	client.MaxRetries = 0
+	client.Transport = &gemini.TLSTransport{HandshakeTimeout: 100 * time.Millisecond}
+	start := time.Now()
+	_, err := client.NavigatePage("gemini://" + addr + "/")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+// serveSOCKS5 serves a SOCKS5 proxy without authentication that connects
+// every request to target. Requested host names are sent on hosts.
+func serveSOCKS5(t *testing.T, target string, hosts chan<- string) string {
+	return testserver.TCP(t, func(conn net.Conn) {
+		// Greeting
+		greeting := make([]byte, 2)
+		if _, err := io.ReadFull(conn, greeting); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(conn, make([]byte, greeting[1])); err != nil {
+			return
+		}
+		conn.Write([]byte{5, 0})
+		// Connect request with a domain name
+		request := make([]byte, 5)
+		if _, err := io.ReadFull(conn, request); err != nil || request[3] != 3 {
+			return
+		}
+		host := make([]byte, request[4]+2)
+		if _, err := io.ReadFull(conn, host); err != nil {
+			return
+		}
+		port := binary.BigEndian.Uint16(host[len(host)-2:])
+		hosts <- net.JoinHostPort(string(host[:len(host)-2]), strconv.Itoa(int(port)))
+		upstream, err := net.Dial("tcp", target)
+		if err != nil {
+			return
+		}
+		defer upstream.Close()
+		conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+		go io.Copy(upstream, conn)
+		io.Copy(conn, upstream)
+	})
+}
+
+// TestSOCKS5Dialer tests reaching an onion address through a SOCKS5 proxy.
+func TestSOCKS5Dialer(t *testing.T) {
+	base := serveFunc(t, func(requestUrl string) string {
+		return "20 text/gemini\r\nonion"
+	})
+	hosts := make(chan string, 1)
+	proxyAddr := serveSOCKS5(t, strings.TrimPrefix(base, "gemini://"), hosts)
+
+	dialer, err := gemini.NewSOCKS5Dialer(proxyAddr, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := gemini.MakeClient()
+	client.Transport = &gemini.TLSTransport{Dialer: dialer}
+	resp, err := client.NavigatePage("gemini://example.onion/")
+	if assert.Nil(t, err) {
+		data, _ := resp.Response.ReadAll(0)
+		assert.Equal(t, "onion", string(data))
+		assert.Equal(t, "example.onion:1965", <-hosts)
+	}
+}
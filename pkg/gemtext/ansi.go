@@ -0,0 +1,142 @@
+package gemtext
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ANSI escape sequences used by RenderAnsi.
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiItalic    = "\x1b[3m"
+	ansiHeading1  = "\x1b[1;4;35m"
+	ansiHeading2  = "\x1b[1;36m"
+	ansiHeading3  = "\x1b[1;32m"
+	ansiLinkIndex = "\x1b[1;34m"
+	ansiQuote     = "\x1b[3;37m"
+)
+
+// ansiHeadings are the styles of each heading level.
+var ansiHeadings = map[int]string{
+	1: ansiHeading1,
+	2: ansiHeading2,
+	3: ansiHeading3,
+}
+
+// AnsiOptions configures terminal rendering.
+type AnsiOptions struct {
+	// Width is the width in cells to wrap lines to. If 0 or less, lines are not wrapped.
+	Width int
+	// NoColor leaves out ANSI escape sequences.
+	NoColor bool
+}
+
+// ConvertToAnsi converts gemtext to ANSI-styled terminal text.
+func ConvertToAnsi(text string, options AnsiOptions) string {
+	return RenderAnsi(ParseString(text), options)
+}
+
+// RenderAnsi renders a document as ANSI-styled terminal text. Links and
+// prompts are numbered from 1 in the order LinkUrls gives, as `[3] label`.
+// Preformatted blocks are never wrapped. Control characters other than tab
+// are stripped so the document cannot send its own escape sequences.
+func RenderAnsi(doc *Document, options AnsiOptions) string {
+	var b strings.Builder
+	style := func(code string, text string) string {
+		if options.NoColor {
+			return text
+		}
+		return code + text + ansiReset
+	}
+	writeWrapped := func(firstPrefix string, restPrefix string, code string, text string) {
+		prefixWidth := StringWidth(restPrefix)
+		if w := StringWidth(firstPrefix); w > prefixWidth {
+			prefixWidth = w
+		}
+		text = StripControl(text)
+		textWidth := options.Width - prefixWidth
+		if options.Width > 0 && textWidth < 1 {
+			textWidth = 1
+		}
+		for i, line := range Wrap(text, textWidth) {
+			prefix := restPrefix
+			if i == 0 {
+				prefix = firstPrefix
+			}
+			if len(code) > 0 && len(line) > 0 {
+				line = style(code, line)
+			}
+			b.WriteString(prefix + line + "\n")
+		}
+	}
+
+	linkIndex := 0
+	for _, line := range doc.Lines {
+		switch line := line.(type) {
+		case Heading:
+			marker := strings.Repeat("#", line.Level) + " "
+			writeWrapped(style(ansiBold, marker), strings.Repeat(" ", len(marker)), ansiHeadings[line.Level], line.Text)
+		case Link, Prompt:
+			linkIndex++
+			url, label := linkParts(line)
+			if len(label) == 0 {
+				label = url
+			}
+			number := "[" + strconv.Itoa(linkIndex) + "] "
+			writeWrapped(style(ansiLinkIndex, number), strings.Repeat(" ", len(number)), "", label)
+		case ListItem:
+			writeWrapped("• ", "  ", "", line.Text)
+		case Quote:
+			writeWrapped("  │ ", "  │ ", ansiQuote, line.Text)
+		case Preformatted:
+			for _, preformattedLine := range line.Lines {
+				b.WriteString(StripControl(preformattedLine) + "\n")
+			}
+		case Text:
+			writeWrapped("", "", "", line.Text)
+		}
+	}
+	return b.String()
+}
+
+// StripControl removes C0 and C1 control characters other than tab and
+// newline, e.g. the escape that starts a terminal escape sequence. Bytes that
+// are not valid UTF-8 are kept.
+func StripControl(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !unicode.Is(unicode.Cc, r) || r == '\t' || r == '\n' {
+			b.WriteString(text[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// LinkUrls gets the urls of the links and prompts of a document in order.
+func LinkUrls(doc *Document) []string {
+	urls := make([]string, 0)
+	for _, line := range doc.Lines {
+		switch line.(type) {
+		case Link, Prompt:
+			url, _ := linkParts(line)
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// linkParts gets the url and label of a link or prompt.
+func linkParts(line Line) (string, string) {
+	switch line := line.(type) {
+	case Link:
+		return line.Url, line.Label
+	case Prompt:
+		return line.Url, line.Label
+	}
+	return "", ""
+}
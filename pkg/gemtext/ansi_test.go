@@ -0,0 +1,72 @@
+package gemtext_test
+
+import (
+	"testing"
+
+	"github.com/jasmaa/hikawa/pkg/gemtext"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAnsiPlain tests terminal rendering without color.
+func TestAnsiPlain(t *testing.T) {
+	text := "# A long heading here\n" +
+		"Some text that wraps around\n" +
+		"=> gemini://example.org/ Example capsule link\n" +
+		"=> /bare\n" +
+		"* list item that wraps\n" +
+		"> quoted text that wraps\n" +
+		"```\n" +
+		"preformatted lines are never wrapped\n" +
+		"```\n" +
+		"=: /sign Sign"
+	assert.Equal(t, "# A long\n"+
+		"  heading\n"+
+		"  here\n"+
+		"Some text\n"+
+		"that wraps\n"+
+		"around\n"+
+		"[1] Example\n"+
+		"    capsule\n"+
+		"    link\n"+
+		"[2] /bare\n"+
+		"• list item\n"+
+		"  that wraps\n"+
+		"  │ quoted\n"+
+		"  │ text\n"+
+		"  │ that\n"+
+		"  │ wraps\n"+
+		"preformatted lines are never wrapped\n"+
+		"[3] Sign\n", gemtext.ConvertToAnsi(text, gemtext.AnsiOptions{Width: 12, NoColor: true}))
+}
+
+// TestAnsiColor tests styling headings, link numbers and quotes.
+func TestAnsiColor(t *testing.T) {
+	assert.Equal(t, "\x1b[1m# \x1b[0m\x1b[1;4;35mTitle\x1b[0m\n", gemtext.ConvertToAnsi("# Title", gemtext.AnsiOptions{}))
+	assert.Equal(t, "\x1b[1m## \x1b[0m\x1b[1;36mSection\x1b[0m\n", gemtext.ConvertToAnsi("## Section", gemtext.AnsiOptions{}))
+	assert.Equal(t, "\x1b[1;34m[1] \x1b[0mLink\n", gemtext.ConvertToAnsi("=> /a Link", gemtext.AnsiOptions{}))
+	assert.Equal(t, "  │ \x1b[3;37mQuote\x1b[0m\n", gemtext.ConvertToAnsi("> Quote", gemtext.AnsiOptions{}))
+}
+
+// TestAnsiControl tests stripping escape sequences and control characters
+// from every kind of line.
+func TestAnsiControl(t *testing.T) {
+	text := "Copy \x1b]52;c;aGVsbG8=\x07this\r\n" +
+		"=> /a \x1b[2JLink\n" +
+		"```\n" +
+		"\x1b]52;c;aGVsbG8=\x07pre\tformatted\u009b2J\n" +
+		"```\n"
+	assert.Equal(t, "Copy ]52;c;aGVsbG8=this\n"+
+		"[1] [2JLink\n"+
+		"]52;c;aGVsbG8=pre\tformatted2J\n", gemtext.ConvertToAnsi(text, gemtext.AnsiOptions{NoColor: true}))
+}
+
+// TestStripControl tests keeping tabs, newlines and invalid UTF-8.
+func TestStripControl(t *testing.T) {
+	assert.Equal(t, "a\tb\nc\xff", gemtext.StripControl("a\tb\x00\r\nc\u0085\xff"))
+}
+
+// TestLinkUrls tests numbering links and prompts in order.
+func TestLinkUrls(t *testing.T) {
+	doc := gemtext.ParseString("=> /a A\ntext\n=: /b B\n```\n=> /not-a-link\n```\n=> /c")
+	assert.Equal(t, []string{"/a", "/b", "/c"}, gemtext.LinkUrls(doc))
+}
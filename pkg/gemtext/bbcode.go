@@ -4,45 +4,135 @@ import (
 	"strings"
 )
 
+// BbcodeDialect is a flavor of bbcode.
+type BbcodeDialect int
+
+const (
+	// BBCODE_DIALECT_FORUM is the bbcode of phpBB and most forums.
+	BBCODE_DIALECT_FORUM BbcodeDialect = iota
+	// BBCODE_DIALECT_GODOT is the bbcode of Godot 4 RichTextLabels.
+	BBCODE_DIALECT_GODOT
+)
+
+// forumHeadingStyles are the heading styles used for forums when none are given.
+var forumHeadingStyles = map[int][]string{
+	1: {"size=200", "b"},
+	2: {"size=150", "b"},
+	3: {"b"},
+}
+
+// godotHeadingStyles are the heading styles used for Godot when none are given.
+var godotHeadingStyles = map[int][]string{
+	1: {"font_size=28", "b"},
+	2: {"font_size=22", "b"},
+	3: {"font_size=18", "b"},
+}
+
+// BbcodeOptions configures bbcode rendering.
+type BbcodeOptions struct {
+	// Dialect is the flavor of bbcode to render.
+	Dialect BbcodeDialect
+	// HeadingStyles are the tags headings of each level are wrapped in,
+	// outermost first, like {"size=150", "b"}. If nil, the dialect's
+	// defaults are used.
+	HeadingStyles map[int][]string
+}
+
 // ConvertToBbcode converts gemtext to bbcode.
-func ConvertToBbcode(text string) string {
-	text = strings.ReplaceAll(text, "\r\n", "\n")
-	gemtextList := strings.Split(text, "\n")
-	bbcodeList := make([]string, 0)
-	isPreformatMode := false
-	for _, gemtext := range gemtextList {
-		bbcode := gemtext
-		if !isPreformatMode && heading3Re.MatchString(gemtext) {
-			// Heading 3
-			bbcode = heading3Re.ReplaceAllString(gemtext, "[font=res://assets/fonts/Ubuntu/UbuntuR_H3.tres]${text}[/font]")
-		} else if !isPreformatMode && heading2Re.MatchString(gemtext) {
-			// Heading 2
-			bbcode = heading2Re.ReplaceAllString(gemtext, "[font=res://assets/fonts/Ubuntu/UbuntuR_H2.tres]${text}[/font]")
-		} else if !isPreformatMode && heading1Re.MatchString(gemtext) {
-			// Heading 1
-			bbcode = heading1Re.ReplaceAllString(gemtext, "[font=res://assets/fonts/Ubuntu/UbuntuR_H1.tres]${text}[/font]")
-		} else if !isPreformatMode && linkRe.MatchString(gemtext) {
-			// Link with name
-			bbcode = linkRe.ReplaceAllString(gemtext, "[url=${url}]${text}[/url]")
-		} else if !isPreformatMode && linkRe2.MatchString(gemtext) {
-			// Link without name
-			bbcode = linkRe2.ReplaceAllString(gemtext, "[url=${url}]${url}[/url]")
-		} else if !isPreformatMode && listBulletRe.MatchString(gemtext) {
-			// List
-			bbcode = listBulletRe.ReplaceAllString(gemtext, "[indent]* ${text}[/indent]")
-		} else if !isPreformatMode && blockquoteRe.MatchString(gemtext) {
-			// Blockquote
-			bbcode = blockquoteRe.ReplaceAllString(gemtext, "[center]${text}[/center]")
-		} else if preformattedRe.MatchString(gemtext) {
-			// Preformat
-			if !isPreformatMode {
-				bbcode = "[code]"
+func ConvertToBbcode(text string, options BbcodeOptions) string {
+	return RenderBbcode(ParseString(text), options)
+}
+
+// RenderBbcode renders a document as bbcode. Square brackets in text and
+// preformatted blocks are escaped so they are not read as tags. Consecutive
+// list items are grouped in one list.
+func RenderBbcode(doc *Document, options BbcodeOptions) string {
+	headingStyles := options.HeadingStyles
+	if headingStyles == nil {
+		headingStyles = forumHeadingStyles
+		if options.Dialect == BBCODE_DIALECT_GODOT {
+			headingStyles = godotHeadingStyles
+		}
+	}
+	escape := bbcodeEscaper(options.Dialect)
+
+	bbcodeList := make([]string, 0, len(doc.Lines))
+	var listItems []string
+	flushList := func() {
+		if len(listItems) == 0 {
+			return
+		}
+		if options.Dialect == BBCODE_DIALECT_GODOT {
+			bbcodeList = append(bbcodeList, "[ul]"+strings.Join(listItems, "\n")+"[/ul]")
+		} else {
+			bbcodeList = append(bbcodeList, "[list]")
+			for _, item := range listItems {
+				bbcodeList = append(bbcodeList, "[*]"+item)
+			}
+			bbcodeList = append(bbcodeList, "[/list]")
+		}
+		listItems = nil
+	}
+
+	for _, line := range doc.Lines {
+		if item, ok := line.(ListItem); ok {
+			listItems = append(listItems, escape.Replace(item.Text))
+			continue
+		}
+		flushList()
+
+		switch line := line.(type) {
+		case Heading:
+			bbcodeList = append(bbcodeList, bbcodeWrap(headingStyles[line.Level], escape.Replace(line.Text)))
+		case Link:
+			bbcodeList = append(bbcodeList, bbcodeLink(escape, line.Url, line.Label))
+		case Prompt:
+			bbcodeList = append(bbcodeList, bbcodeLink(escape, line.Url, line.Label))
+		case Quote:
+			if options.Dialect == BBCODE_DIALECT_GODOT {
+				bbcodeList = append(bbcodeList, "[indent][i]"+escape.Replace(line.Text)+"[/i][/indent]")
 			} else {
-				bbcode = "[/code]"
+				bbcodeList = append(bbcodeList, "[quote]"+escape.Replace(line.Text)+"[/quote]")
+			}
+		case Preformatted:
+			bbcodeList = append(bbcodeList, "[code]")
+			for _, preformattedLine := range line.Lines {
+				bbcodeList = append(bbcodeList, escape.Replace(preformattedLine))
 			}
-			isPreformatMode = !isPreformatMode
+			bbcodeList = append(bbcodeList, "[/code]")
+		default:
+			// Text lines are the same in bbcode
+			bbcodeList = append(bbcodeList, escape.Replace(line.String()))
 		}
-		bbcodeList = append(bbcodeList, bbcode)
 	}
+	flushList()
 	return strings.Join(bbcodeList, "\n")
 }
+
+// bbcodeEscaper escapes square brackets for a dialect. Godot has tags for
+// them, and forums show HTML character references as the character.
+func bbcodeEscaper(dialect BbcodeDialect) *strings.Replacer {
+	if dialect == BBCODE_DIALECT_GODOT {
+		return strings.NewReplacer("[", "[lb]", "]", "[rb]")
+	}
+	return strings.NewReplacer("[", "&#91;", "]", "&#93;")
+}
+
+// bbcodeWrap wraps text in tags, outermost first.
+func bbcodeWrap(tags []string, text string) string {
+	for i := len(tags) - 1; i >= 0; i-- {
+		name, _, _ := strings.Cut(tags[i], "=")
+		text = "[" + tags[i] + "]" + text + "[/" + name + "]"
+	}
+	return text
+}
+
+// bbcodeLink makes a bbcode link, labelled with its url if it has no label.
+// Square brackets in the url are percent-encoded.
+func bbcodeLink(escape *strings.Replacer, url string, label string) string {
+	if len(label) == 0 {
+		label = url
+	}
+	url = strings.NewReplacer("[", "%5B", "]", "%5D").Replace(url)
+	return "[url=" + url + "]" + escape.Replace(label) + "[/url]"
+}
@@ -1,6 +1,7 @@
 package gemtext_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/jasmaa/hikawa/pkg/gemtext"
@@ -24,8 +25,98 @@ func TestToBbcodeLinks(t *testing.T) {
 	for i := 0; i < len(gemtextList); i++ {
 		gemtextContent := gemtextList[i]
 		targetBbcodeContent := targetBbcodeList[i]
-		assert.Equal(t, targetBbcodeContent, gemtext.ConvertToBbcode(gemtextContent))
+		assert.Equal(t, targetBbcodeContent, gemtext.ConvertToBbcode(gemtextContent, gemtext.BbcodeOptions{}))
 	}
 }
 
-// TODO: add remaining tests when bbcode mapped
+// TestToBbcodePrompts tests gemtext to bbcode for Spartan input prompts.
+func TestToBbcodePrompts(t *testing.T) {
+	assert.Equal(t, "[url=/guestbook]Sign the guestbook[/url]", gemtext.ConvertToBbcode("=: /guestbook Sign the guestbook", gemtext.BbcodeOptions{}))
+}
+
+// TestToBbcodeHeadings tests gemtext to bbcode for headings in each dialect.
+func TestToBbcodeHeadings(t *testing.T) {
+	text := "# One\n## Two\n### Three"
+	assert.Equal(t,
+		"[size=200][b]One[/b][/size]\n[size=150][b]Two[/b][/size]\n[b]Three[/b]",
+		gemtext.ConvertToBbcode(text, gemtext.BbcodeOptions{}))
+	assert.Equal(t,
+		"[font_size=28][b]One[/b][/font_size]\n[font_size=22][b]Two[/b][/font_size]\n[font_size=18][b]Three[/b][/font_size]",
+		gemtext.ConvertToBbcode(text, gemtext.BbcodeOptions{Dialect: gemtext.BBCODE_DIALECT_GODOT}))
+}
+
+// TestToBbcodeHeadingStyles tests gemtext to bbcode with custom heading styles.
+func TestToBbcodeHeadingStyles(t *testing.T) {
+	options := gemtext.BbcodeOptions{
+		HeadingStyles: map[int][]string{
+			1: {"color=#ff0000", "u"},
+		},
+	}
+	assert.Equal(t,
+		"[color=#ff0000][u]One[/u][/color]\nTwo",
+		gemtext.ConvertToBbcode("# One\n## Two", options))
+}
+
+// TestToBbcodeText tests gemtext to bbcode for text and blank lines.
+func TestToBbcodeText(t *testing.T) {
+	assert.Equal(t, "Hello\n\nworld", gemtext.ConvertToBbcode("Hello\n\nworld", gemtext.BbcodeOptions{}))
+}
+
+// TestToBbcodeListItems tests gemtext to bbcode for grouping list items.
+func TestToBbcodeListItems(t *testing.T) {
+	text := "* one\n* two\nbetween\n* three"
+	assert.Equal(t,
+		"[list]\n[*]one\n[*]two\n[/list]\nbetween\n[list]\n[*]three\n[/list]",
+		gemtext.ConvertToBbcode(text, gemtext.BbcodeOptions{}))
+	assert.Equal(t,
+		"[ul]one\ntwo[/ul]\nbetween\n[ul]three[/ul]",
+		gemtext.ConvertToBbcode(text, gemtext.BbcodeOptions{Dialect: gemtext.BBCODE_DIALECT_GODOT}))
+}
+
+// TestToBbcodeQuotes tests gemtext to bbcode for quotes.
+func TestToBbcodeQuotes(t *testing.T) {
+	assert.Equal(t, "[quote]To be[/quote]", gemtext.ConvertToBbcode("> To be", gemtext.BbcodeOptions{}))
+	assert.Equal(t,
+		"[indent][i]To be[/i][/indent]",
+		gemtext.ConvertToBbcode("> To be", gemtext.BbcodeOptions{Dialect: gemtext.BBCODE_DIALECT_GODOT}))
+}
+
+// TestToBbcodePreformatted tests gemtext to bbcode for preformatted blocks.
+func TestToBbcodePreformatted(t *testing.T) {
+	text := "```go\nfunc main() {\n\tx := a[0]\n}\n```"
+	assert.Equal(t,
+		"[code]\nfunc main() {\n\tx := a&#91;0&#93;\n}\n[/code]",
+		gemtext.ConvertToBbcode(text, gemtext.BbcodeOptions{}))
+	assert.Equal(t,
+		"[code]\nfunc main() {\n\tx := a[lb]0[rb]\n}\n[/code]",
+		gemtext.ConvertToBbcode(text, gemtext.BbcodeOptions{Dialect: gemtext.BBCODE_DIALECT_GODOT}))
+}
+
+// TestToBbcodeEscaping tests escaping markup in every line type.
+func TestToBbcodeEscaping(t *testing.T) {
+	text := strings.Join([]string{
+		"# [b]bold[/b]",
+		"[url=javascript:x]text[/url]",
+		"=> gemini://example.com/[x] [img]label[/img]",
+		"* [i]item",
+		"> [/quote]",
+		"```",
+		"[/code][url]gemini://example.com[/url]",
+		"```",
+	}, "\n")
+	assert.Equal(t, strings.Join([]string{
+		"[size=200][b]&#91;b&#93;bold&#91;/b&#93;[/b][/size]",
+		"&#91;url=javascript:x&#93;text&#91;/url&#93;",
+		"[url=gemini://example.com/%5Bx%5D]&#91;img&#93;label&#91;/img&#93;[/url]",
+		"[list]",
+		"[*]&#91;i&#93;item",
+		"[/list]",
+		"[quote]&#91;/quote&#93;[/quote]",
+		"[code]",
+		"&#91;/code&#93;&#91;url&#93;gemini://example.com&#91;/url&#93;",
+		"[/code]",
+	}, "\n"), gemtext.ConvertToBbcode(text, gemtext.BbcodeOptions{}))
+	assert.Equal(t,
+		"[lb]/code[rb][lb]lb[rb]",
+		gemtext.ConvertToBbcode("[/code][lb]", gemtext.BbcodeOptions{Dialect: gemtext.BBCODE_DIALECT_GODOT}))
+}
@@ -0,0 +1,120 @@
+package gemtext
+
+import (
+	"io"
+	"strings"
+)
+
+// Document is parsed gemtext.
+type Document struct {
+	Lines []Line
+}
+
+// Line is a line of a Document, or a whole block for Preformatted.
+type Line interface {
+	// String gets the line as gemtext, without a trailing newline.
+	String() string
+}
+
+// Text is a text line.
+type Text struct {
+	Text string
+}
+
+// Link is a link line, `=> url label`. Label may be empty.
+type Link struct {
+	Url   string
+	Label string
+}
+
+// Prompt is a Spartan input prompt line, `=: url label`. Label may be empty.
+type Prompt struct {
+	Url   string
+	Label string
+}
+
+// Heading is a heading line with a Level of 1 to 3.
+type Heading struct {
+	Level int
+	Text  string
+}
+
+// ListItem is an unordered list item line, `* text`.
+type ListItem struct {
+	Text string
+}
+
+// Quote is a quote line, `> text`.
+type Quote struct {
+	Text string
+}
+
+// Preformatted is a preformatted block. Alt is the text after the opening
+// toggle line and Lines are the lines inside the block.
+type Preformatted struct {
+	Alt   string
+	Lines []string
+}
+
+func (l Text) String() string {
+	return l.Text
+}
+
+func (l Link) String() string {
+	if len(l.Label) == 0 {
+		return "=> " + l.Url
+	}
+	return "=> " + l.Url + " " + l.Label
+}
+
+func (l Prompt) String() string {
+	if len(l.Label) == 0 {
+		return "=: " + l.Url
+	}
+	return "=: " + l.Url + " " + l.Label
+}
+
+func (l Heading) String() string {
+	if len(l.Text) == 0 {
+		return strings.Repeat("#", l.Level)
+	}
+	return strings.Repeat("#", l.Level) + " " + l.Text
+}
+
+func (l ListItem) String() string {
+	return "* " + l.Text
+}
+
+func (l Quote) String() string {
+	if len(l.Text) == 0 {
+		return ">"
+	}
+	return "> " + l.Text
+}
+
+func (l Preformatted) String() string {
+	var b strings.Builder
+	b.WriteString("```" + l.Alt + "\n")
+	for _, line := range l.Lines {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("```")
+	return b.String()
+}
+
+// String serializes a document back to gemtext with LF line endings.
+// Parsing the result gives an equal document.
+func (d *Document) String() string {
+	var b strings.Builder
+	for _, line := range d.Lines {
+		b.WriteString(line.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// WriteTo writes a document as gemtext to w.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, d.String())
+	return int64(n), err
+}
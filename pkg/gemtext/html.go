@@ -0,0 +1,189 @@
+package gemtext
+
+import (
+	"html"
+	"net/url"
+	"strings"
+)
+
+// DEFAULT_HTML_CSS is the theme used for full HTML documents when none is given.
+const DEFAULT_HTML_CSS = `body {
+  max-width: 42rem;
+  margin: 2rem auto;
+  padding: 0 1rem;
+  font-family: system-ui, sans-serif;
+  line-height: 1.5;
+  color: #222;
+  background: #fdfdfd;
+}
+h1, h2, h3 { line-height: 1.2; }
+a { color: #2a5db0; }
+p.link, p.prompt { margin: 0.25rem 0; }
+p.link a::before { content: "\21D2\00A0"; }
+blockquote {
+  margin: 1rem 0;
+  padding-left: 1rem;
+  border-left: 3px solid #ccc;
+  color: #555;
+}
+pre {
+  overflow-x: auto;
+  padding: 0.5rem;
+  background: #f2f2f2;
+}
+@media (prefers-color-scheme: dark) {
+  body { color: #ddd; background: #1b1b1b; }
+  a { color: #8ab4f8; }
+  blockquote { border-color: #555; color: #aaa; }
+  pre { background: #262626; }
+}
+`
+
+// HtmlOptions configures HTML rendering.
+type HtmlOptions struct {
+	// BaseUrl is the url relative links are resolved against. If empty,
+	// relative links are kept as they are.
+	BaseUrl string
+	// FullDocument wraps the HTML in a full HTML5 document with a stylesheet.
+	FullDocument bool
+	// Title is the title of a full document. If empty, the first heading is used.
+	Title string
+	// Lang is the language of a full document, if known.
+	Lang string
+	// CSS is the stylesheet of a full document. If empty, DEFAULT_HTML_CSS is used.
+	CSS string
+}
+
+// ConvertToHtml converts gemtext to HTML5.
+func ConvertToHtml(text string, options HtmlOptions) string {
+	return RenderHtml(ParseString(text), options)
+}
+
+// RenderHtml renders a document as semantic, escaped HTML5. Consecutive list
+// items are grouped in a list and consecutive quotes in a blockquote.
+// Links with unsafe schemes like javascript: are not linked.
+func RenderHtml(doc *Document, options HtmlOptions) string {
+	var base *url.URL
+	if len(options.BaseUrl) > 0 {
+		base, _ = url.Parse(options.BaseUrl)
+	}
+
+	var body strings.Builder
+	var openBlock string
+	closeBlock := func() {
+		if len(openBlock) > 0 {
+			body.WriteString("</" + openBlock + ">\n")
+			openBlock = ""
+		}
+	}
+	openBlockFor := func(tag string) {
+		if openBlock != tag {
+			closeBlock()
+			body.WriteString("<" + tag + ">\n")
+			openBlock = tag
+		}
+	}
+
+	for _, line := range doc.Lines {
+		switch line := line.(type) {
+		case ListItem:
+			openBlockFor("ul")
+			body.WriteString("<li>" + html.EscapeString(line.Text) + "</li>\n")
+			continue
+		case Quote:
+			openBlockFor("blockquote")
+			body.WriteString("<p>" + html.EscapeString(line.Text) + "</p>\n")
+			continue
+		}
+		closeBlock()
+
+		switch line := line.(type) {
+		case Heading:
+			tag := "h" + string(rune('0'+line.Level))
+			body.WriteString("<" + tag + ">" + html.EscapeString(line.Text) + "</" + tag + ">\n")
+		case Link:
+			body.WriteString(`<p class="link">` + htmlLink(base, line.Url, line.Label) + "</p>\n")
+		case Prompt:
+			body.WriteString(`<p class="prompt">` + htmlLink(base, line.Url, line.Label) + "</p>\n")
+		case Preformatted:
+			if len(line.Alt) > 0 {
+				body.WriteString(`<pre aria-label="` + html.EscapeString(line.Alt) + `">`)
+			} else {
+				body.WriteString("<pre>")
+			}
+			body.WriteString(html.EscapeString(strings.Join(line.Lines, "\n")) + "</pre>\n")
+		case Text:
+			// Blank lines only separate paragraphs
+			if len(strings.TrimSpace(line.Text)) > 0 {
+				body.WriteString("<p>" + html.EscapeString(line.Text) + "</p>\n")
+			}
+		}
+	}
+	closeBlock()
+
+	if !options.FullDocument {
+		return body.String()
+	}
+	return htmlDocument(doc, options, body.String())
+}
+
+// htmlDocument wraps an HTML body in a full document.
+func htmlDocument(doc *Document, options HtmlOptions, body string) string {
+	title := options.Title
+	if len(title) == 0 {
+		for _, line := range doc.Lines {
+			if heading, ok := line.(Heading); ok {
+				title = heading.Text
+				break
+			}
+		}
+	}
+	css := options.CSS
+	if len(css) == 0 {
+		css = DEFAULT_HTML_CSS
+	}
+
+	var document strings.Builder
+	document.WriteString("<!DOCTYPE html>\n")
+	if len(options.Lang) > 0 {
+		document.WriteString(`<html lang="` + html.EscapeString(options.Lang) + `">` + "\n")
+	} else {
+		document.WriteString("<html>\n")
+	}
+	document.WriteString("<head>\n")
+	document.WriteString(`<meta charset="utf-8">` + "\n")
+	document.WriteString(`<meta name="viewport" content="width=device-width, initial-scale=1">` + "\n")
+	document.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+	document.WriteString("<style>\n" + strings.ReplaceAll(css, "</", `<\/`) + "</style>\n")
+	document.WriteString("</head>\n")
+	document.WriteString("<body>\n")
+	document.WriteString(body)
+	document.WriteString("</body>\n")
+	document.WriteString("</html>\n")
+	return document.String()
+}
+
+// htmlLink makes an anchor for a link, resolving relative urls against base.
+func htmlLink(base *url.URL, rawurl string, label string) string {
+	if len(label) == 0 {
+		label = rawurl
+	}
+	href := rawurl
+	if ref, err := url.Parse(rawurl); err != nil {
+		return html.EscapeString(label)
+	} else if isUnsafeScheme(ref.Scheme) {
+		return html.EscapeString(label)
+	} else if base != nil && len(ref.Scheme) == 0 {
+		href = base.ResolveReference(ref).String()
+	}
+	return `<a href="` + html.EscapeString(href) + `">` + html.EscapeString(label) + "</a>"
+}
+
+// isUnsafeScheme checks if a scheme can run script in a browser.
+func isUnsafeScheme(scheme string) bool {
+	switch strings.ToLower(scheme) {
+	case "javascript", "vbscript", "data":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package gemtext_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jasmaa/hikawa/pkg/gemtext"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHtmlLines tests gemtext to HTML for each line type.
+func TestHtmlLines(t *testing.T) {
+	text := "# Title\n" +
+		"## Section\n" +
+		"### Sub <section>\n" +
+		"\n" +
+		"Fish & chips\n" +
+		"* one\n" +
+		"* <two>\n" +
+		"> quoted\n" +
+		"> again\n" +
+		"After\n" +
+		"```ascii cat\n" +
+		" /\\_/\\  <meow>\n" +
+		"( o.o )\n" +
+		"```\n" +
+		"```\n" +
+		"no alt\n" +
+		"```\n"
+	assert.Equal(t, "<h1>Title</h1>\n"+
+		"<h2>Section</h2>\n"+
+		"<h3>Sub &lt;section&gt;</h3>\n"+
+		"<p>Fish &amp; chips</p>\n"+
+		"<ul>\n"+
+		"<li>one</li>\n"+
+		"<li>&lt;two&gt;</li>\n"+
+		"</ul>\n"+
+		"<blockquote>\n"+
+		"<p>quoted</p>\n"+
+		"<p>again</p>\n"+
+		"</blockquote>\n"+
+		"<p>After</p>\n"+
+		`<pre aria-label="ascii cat"> /\_/\  &lt;meow&gt;`+"\n"+
+		"( o.o )</pre>\n"+
+		"<pre>no alt</pre>\n", gemtext.ConvertToHtml(text, gemtext.HtmlOptions{}))
+}
+
+// TestHtmlLinks tests gemtext to HTML for links.
+func TestHtmlLinks(t *testing.T) {
+	text := "=> gemini://example.org/ Example\n" +
+		"=> page.gmi\n" +
+		"=> /about.gmi?q=a&b=c About & more\n" +
+		"=> javascript:alert(1) Click me\n" +
+		"=: /sign Sign"
+	assert.Equal(t, `<p class="link"><a href="gemini://example.org/">Example</a></p>`+"\n"+
+		`<p class="link"><a href="page.gmi">page.gmi</a></p>`+"\n"+
+		`<p class="link"><a href="/about.gmi?q=a&amp;b=c">About &amp; more</a></p>`+"\n"+
+		`<p class="link">Click me</p>`+"\n"+
+		`<p class="prompt"><a href="/sign">Sign</a></p>`+"\n", gemtext.ConvertToHtml(text, gemtext.HtmlOptions{}))
+
+	options := gemtext.HtmlOptions{BaseUrl: "https://mirror.example.org/capsule/posts/"}
+	assert.Equal(t, `<p class="link"><a href="https://mirror.example.org/capsule/posts/page.gmi">page.gmi</a></p>`+"\n"+
+		`<p class="link"><a href="https://mirror.example.org/about.gmi">About</a></p>`+"\n"+
+		`<p class="link"><a href="gemini://example.org/">gemini://example.org/</a></p>`+"\n",
+		gemtext.ConvertToHtml("=> page.gmi\n=> ../../about.gmi About\n=> gemini://example.org/", options))
+}
+
+// TestHtmlFullDocument tests wrapping HTML in a full document.
+func TestHtmlFullDocument(t *testing.T) {
+	document := gemtext.ConvertToHtml("Intro\n## First <heading>\n", gemtext.HtmlOptions{FullDocument: true, Lang: "en"})
+	assert.True(t, strings.HasPrefix(document, "<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n"))
+	assert.Contains(t, document, "<title>First &lt;heading&gt;</title>\n")
+	assert.Contains(t, document, "<style>\n"+gemtext.DEFAULT_HTML_CSS+"</style>\n")
+	assert.True(t, strings.HasSuffix(document, "<body>\n<p>Intro</p>\n<h2>First &lt;heading&gt;</h2>\n</body>\n</html>\n"))
+
+	document = gemtext.ConvertToHtml("# Heading", gemtext.HtmlOptions{FullDocument: true, Title: "Custom", CSS: "body { color: red; } </style><script>"})
+	assert.Contains(t, document, "<title>Custom</title>\n")
+	assert.Contains(t, document, "<style>\nbody { color: red; } <\\/style><script></style>\n")
+}
@@ -0,0 +1,121 @@
+package gemtext
+
+import (
+	"strings"
+)
+
+// ImguiBlockKind is the kind of widget an ImguiBlock is shown in.
+type ImguiBlockKind int
+
+const (
+	// IMGUI_BLOCK_MARKDOWN is Dear ImGui markdown of headings and links.
+	// https://github.com/juliettef/imgui_markdown
+	IMGUI_BLOCK_MARKDOWN ImguiBlockKind = iota
+	// IMGUI_BLOCK_TEXT is plain text shown in a wrapped label.
+	IMGUI_BLOCK_TEXT
+	// IMGUI_BLOCK_QUOTE is quoted text shown in a wrapped, styled label.
+	IMGUI_BLOCK_QUOTE
+	// IMGUI_BLOCK_PREFORMATTED is preformatted text shown verbatim in a
+	// monospace label.
+	IMGUI_BLOCK_PREFORMATTED
+)
+
+// ImguiBlock is a run of lines shown in one Dear ImGui widget.
+type ImguiBlock struct {
+	Kind ImguiBlockKind
+	Text string
+}
+
+// RenderImgui renders a document as blocks for Dear ImGui widgets. Only
+// headings and links go through the markdown widget, which has no escapes,
+// so text, list items and quotes are never read as markdown. Emphasis in
+// headings and labels is neutralized and link urls are percent-encoded; use
+// ImguiLinkUrl to get back the url of a clicked link. Consecutive
+// lines of the same kind share a block, except preformatted blocks.
+func RenderImgui(doc *Document) []ImguiBlock {
+	var blocks []ImguiBlock
+	add := func(kind ImguiBlockKind, text string) {
+		last := len(blocks) - 1
+		if last >= 0 && blocks[last].Kind == kind && kind != IMGUI_BLOCK_PREFORMATTED {
+			blocks[last].Text += "\n" + text
+			return
+		}
+		blocks = append(blocks, ImguiBlock{Kind: kind, Text: text})
+	}
+
+	for _, line := range doc.Lines {
+		switch line := line.(type) {
+		case Heading:
+			add(IMGUI_BLOCK_MARKDOWN, strings.Repeat("#", line.Level)+" "+imguiEscape(line.Text))
+		case Link:
+			add(IMGUI_BLOCK_MARKDOWN, imguiLink(line.Url, line.Label))
+		case Prompt:
+			add(IMGUI_BLOCK_MARKDOWN, imguiLink(line.Url, line.Label))
+		case ListItem:
+			add(IMGUI_BLOCK_TEXT, "  * "+line.Text)
+		case Quote:
+			add(IMGUI_BLOCK_QUOTE, line.Text)
+		case Preformatted:
+			add(IMGUI_BLOCK_PREFORMATTED, strings.Join(line.Lines, "\n"))
+		default:
+			add(IMGUI_BLOCK_TEXT, line.String())
+		}
+	}
+	return blocks
+}
+
+// imguiEscaper replaces brackets with parentheses and keeps `*` and `_` after
+// whitespace from starting emphasis, which the markdown widget has no escapes
+// for, by putting a no-break space before them instead.
+var imguiEscaper = strings.NewReplacer(
+	"[", "(",
+	"]", ")",
+	" *", "\u00a0*",
+	" _", "\u00a0_",
+	"\t*", "\u00a0*",
+	"\t_", "\u00a0_",
+)
+
+// imguiUrlEscaper percent-encodes characters that end a link destination in
+// the markdown widget. % is encoded too so ImguiLinkUrl can undo it exactly.
+var imguiUrlEscaper = strings.NewReplacer(
+	"%", "%25",
+	" ", "%20",
+	"(", "%28",
+	")", "%29",
+)
+
+// imguiUrlUnescaper undoes imguiUrlEscaper.
+var imguiUrlUnescaper = strings.NewReplacer(
+	"%25", "%",
+	"%20", " ",
+	"%28", "(",
+	"%29", ")",
+)
+
+// ImguiLinkUrl gets the url of a link clicked in an IMGUI_BLOCK_MARKDOWN
+// block, as it was in the document.
+func ImguiLinkUrl(destination string) string {
+	return imguiUrlUnescaper.Replace(destination)
+}
+
+// imguiEscape escapes heading text or a link label for the markdown widget.
+// Brackets would start or end a link, so they are replaced with parentheses,
+// and `*` and `_` at the start or after whitespace get a no-break space
+// before them.
+func imguiEscape(text string) string {
+	text = imguiEscaper.Replace(text)
+	if strings.HasPrefix(text, "*") || strings.HasPrefix(text, "_") {
+		text = "\u00a0" + text
+	}
+	return text
+}
+
+// imguiLink makes a Dear ImGui markdown link, labelled with its url if it has
+// no label.
+func imguiLink(url string, label string) string {
+	if len(label) == 0 {
+		label = url
+	}
+	return "[" + imguiEscape(label) + "](" + imguiUrlEscaper.Replace(url) + ")"
+}
@@ -0,0 +1,54 @@
+package gemtext_test
+
+import (
+	"testing"
+
+	"github.com/jasmaa/hikawa/pkg/gemtext"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRenderImguiBlocks tests grouping lines into widget blocks.
+func TestRenderImguiBlocks(t *testing.T) {
+	doc := gemtext.ParseString("# Title\n=> /about About\nSome _text_\n* [x](y)\n> To be\n> or not\n```art\n /\\_/\\\n( o.o )\n```\n```\nsecond\n```")
+	assert.Equal(t, []gemtext.ImguiBlock{
+		{Kind: gemtext.IMGUI_BLOCK_MARKDOWN, Text: "# Title\n[About](/about)"},
+		{Kind: gemtext.IMGUI_BLOCK_TEXT, Text: "Some _text_\n  * [x](y)"},
+		{Kind: gemtext.IMGUI_BLOCK_QUOTE, Text: "To be\nor not"},
+		{Kind: gemtext.IMGUI_BLOCK_PREFORMATTED, Text: " /\\_/\\\n( o.o )"},
+		{Kind: gemtext.IMGUI_BLOCK_PREFORMATTED, Text: "second"},
+	}, gemtext.RenderImgui(doc))
+}
+
+// TestRenderImguiLinks tests links and prompts in the markdown widget.
+func TestRenderImguiLinks(t *testing.T) {
+	doc := gemtext.ParseString("=> gemini://example.com\n=: /search [beta] Search\n=> /a_(b) _c_")
+	assert.Equal(t, []gemtext.ImguiBlock{
+		{Kind: gemtext.IMGUI_BLOCK_MARKDOWN, Text: "[gemini://example.com](gemini://example.com)\n[(beta) Search](/search)\n[\u00a0_c_](/a_%28b%29)"},
+	}, gemtext.RenderImgui(doc))
+}
+
+// TestRenderImguiEscape tests neutralizing emphasis in headings and labels
+// and encoding urls that would end the link early.
+func TestRenderImguiEscape(t *testing.T) {
+	doc := gemtext.ParseString("## Use *this* or _that_\n=> /a)b%20c A *bold* `code` label")
+	blocks := gemtext.RenderImgui(doc)
+	assert.Equal(t, []gemtext.ImguiBlock{
+		{Kind: gemtext.IMGUI_BLOCK_MARKDOWN, Text: "## Use\u00a0*this* or\u00a0_that_\n[A\u00a0*bold* `code` label](/a%29b%2520c)"},
+	}, blocks)
+	assert.Equal(t, "/a)b%20c", gemtext.ImguiLinkUrl("/a%29b%2520c"))
+	assert.Equal(t, "/a b", gemtext.ImguiLinkUrl("/a%20b"))
+}
+
+// TestRenderImguiEscapeLeading tests neutralizing emphasis at the start of
+// headings and labels, and link syntax in headings.
+func TestRenderImguiEscapeLeading(t *testing.T) {
+	doc := gemtext.ParseString("# *bold* heading\n## see [x](y)\n=> /x _u_")
+	assert.Equal(t, []gemtext.ImguiBlock{
+		{Kind: gemtext.IMGUI_BLOCK_MARKDOWN, Text: "# \u00a0*bold* heading\n## see (x)(y)\n[\u00a0_u_](/x)"},
+	}, gemtext.RenderImgui(doc))
+}
+
+// TestRenderImguiEmpty tests rendering an empty document.
+func TestRenderImguiEmpty(t *testing.T) {
+	assert.Empty(t, gemtext.RenderImgui(gemtext.ParseString("")))
+}
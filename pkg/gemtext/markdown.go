@@ -4,43 +4,181 @@ import (
 	"strings"
 )
 
-// ConvertToMarkdown converts gemtext to Dear ImGui markdown.
-// https://github.com/juliettef/imgui_markdown
+// markdownEscaper escapes characters that are significant anywhere in a line.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	"*", `\*`,
+	"_", `\_`,
+	"[", `\[`,
+	"]", `\]`,
+	"<", `\<`,
+	"&", `\&`,
+)
+
+// markdownUrlEscaper escapes characters that end a markdown link destination.
+var markdownUrlEscaper = strings.NewReplacer(
+	" ", "%20",
+	"(", "%28",
+	")", "%29",
+	"<", "%3C",
+	">", "%3E",
+)
+
+// markdownSpaceEscaper replaces whitespace with character references.
+var markdownSpaceEscaper = strings.NewReplacer(
+	" ", "&#32;",
+	"\t", "&#9;",
+)
+
+// ConvertToMarkdown converts gemtext to CommonMark markdown.
 func ConvertToMarkdown(text string) string {
-	text = strings.ReplaceAll(text, "\r\n", "\n")
-	gemtextList := strings.Split(text, "\n")
-	mdList := make([]string, 0)
-	isPreformatMode := false
-	for _, gemtext := range gemtextList {
-		md := gemtext
-		if !isPreformatMode && heading3Re.MatchString(gemtext) {
-			// Heading 3
-			// no-op
-		} else if !isPreformatMode && heading2Re.MatchString(gemtext) {
-			// Heading 2
-			// no-op
-		} else if !isPreformatMode && heading1Re.MatchString(gemtext) {
-			// Heading 1
-			// no-op
-		} else if !isPreformatMode && linkRe.MatchString(gemtext) {
-			// Link with name
-			md = linkRe.ReplaceAllString(gemtext, "[${text}](${url})")
-		} else if !isPreformatMode && linkRe2.MatchString(gemtext) {
-			// Link without name
-			md = linkRe2.ReplaceAllString(gemtext, "[${url}](${url})")
-		} else if !isPreformatMode && listBulletRe.MatchString(gemtext) {
-			// List
-			md = listBulletRe.ReplaceAllString(gemtext, "  * ${text}")
-		} else if !isPreformatMode && blockquoteRe.MatchString(gemtext) {
-			// Blockquote
-			// no-op
-		} else if preformattedRe.MatchString(gemtext) {
-			// Preformat
-			// Wraps in preformat block in ```. This does not get renderered in the markdown widget.
-			md = "```"
-			isPreformatMode = !isPreformatMode
+	return RenderMarkdown(ParseString(text))
+}
+
+// RenderMarkdown renders a document as CommonMark markdown. Markdown syntax
+// in text is escaped, so the output shows the same text as the gemtext, and
+// preformatted blocks are kept verbatim in fenced code blocks. Consecutive
+// text, link and quote lines end in hard line breaks, and quotes and lists
+// are followed by a blank line, so lines are never joined together.
+func RenderMarkdown(doc *Document) string {
+	mdList := make([]string, 0, len(doc.Lines))
+	var prev Line
+	for _, line := range doc.Lines {
+		if prev != nil {
+			switch {
+			case markdownJoins(prev, line):
+				mdList[len(mdList)-1] += `\`
+			case markdownEndsBlock(prev, line):
+				mdList = append(mdList, "")
+			}
+		}
+		prev = line
+
+		switch line := line.(type) {
+		case Heading:
+			mdList = append(mdList, strings.Repeat("#", line.Level)+" "+EscapeMarkdown(line.Text))
+		case Link:
+			mdList = append(mdList, markdownLink(line.Url, line.Label))
+		case Prompt:
+			mdList = append(mdList, markdownLink(line.Url, line.Label))
+		case ListItem:
+			mdList = append(mdList, "* "+EscapeMarkdown(line.Text))
+		case Quote:
+			mdList = append(mdList, strings.TrimRight("> "+EscapeMarkdown(line.Text), " "))
+		case Preformatted:
+			fence := markdownFence(line.Lines)
+			mdList = append(mdList, fence)
+			mdList = append(mdList, line.Lines...)
+			mdList = append(mdList, fence)
+		default:
+			mdList = append(mdList, EscapeMarkdown(line.String()))
 		}
-		mdList = append(mdList, md)
 	}
 	return strings.Join(mdList, "\n")
 }
+
+// EscapeMarkdown escapes text so markdown shows it as it is. Emphasis, code,
+// link, html and entity characters are escaped anywhere, and characters that
+// start blocks are escaped at the beginning of the line. Leading whitespace
+// starts with a character reference so it is not read as an indented code
+// block.
+func EscapeMarkdown(text string) string {
+	escaped := markdownEscaper.Replace(text)
+
+	trimmed := strings.TrimLeft(escaped, " \t")
+	if len(trimmed) == 0 {
+		return escaped
+	}
+	if trimmed != escaped {
+		return markdownSpaceEscaper.Replace(escaped[:1]) + escaped[1:]
+	}
+	switch escaped[0] {
+	case '#', '>', '-', '+', '=', '|', '~':
+		return `\` + escaped
+	}
+	// Ordered list markers like "1." or "1)"
+	digits := len(escaped) - len(strings.TrimLeft(escaped, "0123456789"))
+	if digits > 0 && digits < len(escaped) && (escaped[digits] == '.' || escaped[digits] == ')') {
+		return escaped[:digits] + `\` + escaped[digits:]
+	}
+	return escaped
+}
+
+// markdownJoins checks if line continues the paragraph or quote of prev, so
+// prev needs a hard line break.
+func markdownJoins(prev Line, line Line) bool {
+	switch prev := prev.(type) {
+	case Text:
+		return !isBlank(prev.Text) && markdownInline(line)
+	case Link, Prompt:
+		return markdownInline(line)
+	case Quote:
+		next, ok := line.(Quote)
+		return ok && !isBlank(prev.Text) && !isBlank(next.Text)
+	}
+	return false
+}
+
+// markdownInline checks if a line is rendered as a line of a paragraph.
+func markdownInline(line Line) bool {
+	switch line := line.(type) {
+	case Text:
+		return !isBlank(line.Text)
+	case Link, Prompt:
+		return true
+	}
+	return false
+}
+
+// isBlank checks if text is empty or only whitespace.
+func isBlank(text string) bool {
+	return len(strings.TrimSpace(text)) == 0
+}
+
+// markdownEndsBlock checks if a blank line is needed after a quote or list
+// item so line is not read as a lazy continuation of it.
+func markdownEndsBlock(prev Line, line Line) bool {
+	if text, ok := line.(Text); ok && isBlank(text.Text) {
+		return false
+	}
+	switch prev.(type) {
+	case Quote:
+		_, ok := line.(Quote)
+		return !ok
+	case ListItem:
+		_, ok := line.(ListItem)
+		return !ok
+	}
+	return false
+}
+
+// markdownLink makes a markdown link, labelled with its url if it has no label.
+func markdownLink(url string, label string) string {
+	if len(label) == 0 {
+		label = url
+	}
+	return "[" + markdownEscaper.Replace(label) + "](" + markdownUrlEscaper.Replace(url) + ")"
+}
+
+// markdownFence makes a code fence longer than any run of backticks in lines.
+func markdownFence(lines []string) string {
+	longest := 0
+	for _, line := range lines {
+		run := 0
+		for _, c := range line {
+			if c == '`' {
+				run++
+				if run > longest {
+					longest = run
+				}
+			} else {
+				run = 0
+			}
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
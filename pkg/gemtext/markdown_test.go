@@ -28,4 +28,107 @@ func TestMarkdownLinks(t *testing.T) {
 	}
 }
 
-// TODO: add remaining tests when markdown mapped
+// TestMarkdownPrompts tests gemtext to markdown for Spartan input prompts.
+func TestMarkdownPrompts(t *testing.T) {
+	assert.Equal(t, "[Sign the guestbook](/guestbook)", gemtext.ConvertToMarkdown("=: /guestbook Sign the guestbook"))
+	assert.Equal(t, "[/search](/search)", gemtext.ConvertToMarkdown("=:/search"))
+}
+
+// TestMarkdownHeadings tests gemtext to markdown for headings.
+func TestMarkdownHeadings(t *testing.T) {
+	assert.Equal(t, "# One\n## Two\n### \\*Three\\*", gemtext.ConvertToMarkdown("# One\n## Two\n### *Three*"))
+}
+
+// TestMarkdownListItems tests gemtext to markdown for list items.
+func TestMarkdownListItems(t *testing.T) {
+	assert.Equal(t, "* one\n* \\_two\\_", gemtext.ConvertToMarkdown("* one\n* _two_"))
+}
+
+// TestMarkdownQuotes tests gemtext to markdown for quotes.
+func TestMarkdownQuotes(t *testing.T) {
+	assert.Equal(t, "> To be\n>\n> \\> or not", gemtext.ConvertToMarkdown("> To be\n>\n>> or not"))
+}
+
+// TestMarkdownEscapeText tests escaping markdown syntax in text lines.
+func TestMarkdownEscapeText(t *testing.T) {
+	textList := []string{
+		`_foo_`,
+		`[x](y)`,
+		"`code` and **bold**",
+		`<b>AT&T</b>`,
+		`C:\Users`,
+		`# not a heading`,
+		`  > not a quote`,
+		`- not a list`,
+		`+ not a list`,
+		`===`,
+		`1. not a list`,
+		`2024) not a list`,
+		`2024 is a year.`,
+		"    indented",
+		"\tindented",
+		``,
+	}
+	targetMdList := []string{
+		`\_foo\_`,
+		`\[x\](y)`,
+		"\\`code\\` and \\*\\*bold\\*\\*",
+		`\<b>AT\&T\</b>`,
+		`C:\\Users`,
+		`\# not a heading`,
+		`&#32; > not a quote`,
+		`\- not a list`,
+		`\+ not a list`,
+		`\===`,
+		`1\. not a list`,
+		`2024\) not a list`,
+		`2024 is a year.`,
+		`&#32;   indented`,
+		`&#9;indented`,
+		``,
+	}
+	for i := 0; i < len(textList); i++ {
+		assert.Equal(t, targetMdList[i], gemtext.EscapeMarkdown(textList[i]))
+	}
+	assert.Equal(t, `\_foo\_`, gemtext.ConvertToMarkdown("_foo_"))
+}
+
+// TestMarkdownBlocks tests keeping lines from joining the block before them.
+func TestMarkdownBlocks(t *testing.T) {
+	tests := []struct {
+		text     string
+		markdown string
+	}{
+		{"> quote\ntext", "> quote\n\ntext"},
+		{"* item\ntext", "* item\n\ntext"},
+		{"* one\n* two", "* one\n* two"},
+		{"one\ntwo", "one\\\ntwo"},
+		{"text\n=> /a Link\nmore", "text\\\n[Link](/a)\\\nmore"},
+		{"> To\n> be", "> To\\\n> be"},
+		{"one\n\ntwo", "one\n\ntwo"},
+		{"> quote\n\ntext", "> quote\n\ntext"},
+		{"text\n    indented", "text\\\n&#32;   indented"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.markdown, gemtext.ConvertToMarkdown(test.text), test.text)
+	}
+}
+
+// TestMarkdownEscapeLinks tests escaping labels and urls of links.
+func TestMarkdownEscapeLinks(t *testing.T) {
+	assert.Equal(t,
+		`[\[draft\] \_notes\_](gemini://example.com/a%20%28b%29)`,
+		gemtext.ConvertToMarkdown("=> gemini://example.com/a%20(b) [draft] _notes_"))
+	assert.Equal(t,
+		"[gemini://example.com/(x)](gemini://example.com/%28x%29)",
+		gemtext.ConvertToMarkdown("=> gemini://example.com/(x)"))
+}
+
+// TestMarkdownPreformatted tests keeping preformatted blocks verbatim.
+func TestMarkdownPreformatted(t *testing.T) {
+	art := "```ascii art\n  /\\_/\\\n ( o.o )\n  > ^ <\n```"
+	assert.Equal(t, "```\n  /\\_/\\\n ( o.o )\n  > ^ <\n```", gemtext.ConvertToMarkdown(art))
+
+	fenced := "```\nuse ```go fences\n```"
+	assert.Equal(t, "````\nuse ```go fences\n````", gemtext.ConvertToMarkdown(fenced))
+}
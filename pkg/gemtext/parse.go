@@ -0,0 +1,101 @@
+package gemtext
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Parse parses gemtext per the Gemini gemtext specification. Lines may end in
+// LF or CRLF.
+//
+// A line starting with "```" toggles preformatted mode, and the rest of an
+// opening toggle line is the alt text. Outside preformatted mode, lines
+// starting with "=>" are links, "=:" are Spartan input prompts, "#", "##" and
+// "###" are headings, "* " are list items, ">" are quotes and all other
+// lines are text. Whitespace after a line type prefix is not part of the
+// line content. A preformatted block left open ends with the document.
+func Parse(r io.Reader) (*Document, error) {
+	doc := &Document{Lines: make([]Line, 0)}
+	reader := bufio.NewReader(r)
+	var block *Preformatted
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(line) == 0 && err == io.EOF {
+			break
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if strings.HasPrefix(line, "```") {
+			if block == nil {
+				block = &Preformatted{Alt: strings.TrimSpace(line[3:]), Lines: make([]string, 0)}
+			} else {
+				doc.Lines = append(doc.Lines, *block)
+				block = nil
+			}
+		} else if block != nil {
+			block.Lines = append(block.Lines, line)
+		} else {
+			doc.Lines = append(doc.Lines, parseLine(line))
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+	if block != nil {
+		doc.Lines = append(doc.Lines, *block)
+	}
+	return doc, nil
+}
+
+// ParseString parses gemtext held in a string.
+func ParseString(text string) *Document {
+	// Reading from a string cannot fail
+	doc, _ := Parse(strings.NewReader(text))
+	return doc
+}
+
+// parseLine parses a line outside preformatted mode.
+func parseLine(line string) Line {
+	switch {
+	case strings.HasPrefix(line, "=>"):
+		if url, label := parseLink(line[2:]); len(url) > 0 {
+			return Link{Url: url, Label: label}
+		}
+	case strings.HasPrefix(line, "=:"):
+		if url, label := parseLink(line[2:]); len(url) > 0 {
+			return Prompt{Url: url, Label: label}
+		}
+	case strings.HasPrefix(line, "#"):
+		level := 1
+		for level < 3 && level < len(line) && line[level] == '#' {
+			level++
+		}
+		return Heading{Level: level, Text: trimLeadingSpace(line[level:])}
+	case strings.HasPrefix(line, "* "):
+		return ListItem{Text: trimLeadingSpace(line[2:])}
+	case strings.HasPrefix(line, ">"):
+		return Quote{Text: trimLeadingSpace(line[1:])}
+	}
+	return Text{Text: line}
+}
+
+// parseLink splits the rest of a link line into its url and label.
+// The url is empty if there is none.
+func parseLink(rest string) (string, string) {
+	rest = trimLeadingSpace(rest)
+	i := strings.IndexAny(rest, " \t")
+	if i < 0 {
+		return rest, ""
+	}
+	return rest[:i], strings.TrimSpace(rest[i:])
+}
+
+// trimLeadingSpace trims spaces and tabs from the start of s.
+func trimLeadingSpace(s string) string {
+	return strings.TrimLeft(s, " \t")
+}
@@ -0,0 +1,112 @@
+package gemtext_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jasmaa/hikawa/pkg/gemtext"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseLines tests parsing each line type per the spec.
+func TestParseLines(t *testing.T) {
+	tests := []struct {
+		gemtext string
+		line    gemtext.Line
+	}{
+		{"Just text", gemtext.Text{Text: "Just text"}},
+		{"  indented", gemtext.Text{Text: "  indented"}},
+		{"=> gemini://example.org/ Example", gemtext.Link{Url: "gemini://example.org/", Label: "Example"}},
+		{"=>gemini://example.org/", gemtext.Link{Url: "gemini://example.org/"}},
+		{"=>\t/page.gmi \t A page  ", gemtext.Link{Url: "/page.gmi", Label: "A page"}},
+		{"=>", gemtext.Text{Text: "=>"}},
+		{"=>   ", gemtext.Text{Text: "=>   "}},
+		{"=: /sign Sign the guestbook", gemtext.Prompt{Url: "/sign", Label: "Sign the guestbook"}},
+		{"# Heading", gemtext.Heading{Level: 1, Text: "Heading"}},
+		{"#Heading", gemtext.Heading{Level: 1, Text: "Heading"}},
+		{"## Sub", gemtext.Heading{Level: 2, Text: "Sub"}},
+		{"###Subsub", gemtext.Heading{Level: 3, Text: "Subsub"}},
+		{"#### Deeper", gemtext.Heading{Level: 3, Text: "# Deeper"}},
+		{"#", gemtext.Heading{Level: 1, Text: ""}},
+		{"* Item", gemtext.ListItem{Text: "Item"}},
+		{"* ", gemtext.ListItem{Text: ""}},
+		{"*Not an item", gemtext.Text{Text: "*Not an item"}},
+		{"** bold?", gemtext.Text{Text: "** bold?"}},
+		{"> Quote", gemtext.Quote{Text: "Quote"}},
+		{">Quote", gemtext.Quote{Text: "Quote"}},
+		{">", gemtext.Quote{Text: ""}},
+	}
+	for _, test := range tests {
+		doc := gemtext.ParseString(test.gemtext)
+		if assert.Len(t, doc.Lines, 1, test.gemtext) {
+			assert.Equal(t, test.line, doc.Lines[0], test.gemtext)
+		}
+	}
+}
+
+// TestParsePreformatted tests preformatted blocks.
+func TestParsePreformatted(t *testing.T) {
+	text := "```ascii art\r\n" +
+		"=> not a link\r\n" +
+		"# not a heading\r\n" +
+		"```closing alt is ignored\r\n" +
+		"after\r\n" +
+		"```\r\n" +
+		"unclosed"
+	doc := gemtext.ParseString(text)
+	assert.Equal(t, []gemtext.Line{
+		gemtext.Preformatted{Alt: "ascii art", Lines: []string{"=> not a link", "# not a heading"}},
+		gemtext.Text{Text: "after"},
+		gemtext.Preformatted{Alt: "", Lines: []string{"unclosed"}},
+	}, doc.Lines)
+}
+
+// TestParseLineEndings tests LF and CRLF line endings and a missing final newline.
+func TestParseLineEndings(t *testing.T) {
+	expected := []gemtext.Line{
+		gemtext.Heading{Level: 1, Text: "Title"},
+		gemtext.Text{Text: ""},
+		gemtext.Text{Text: "Body"},
+	}
+	for _, text := range []string{"# Title\n\nBody\n", "# Title\r\n\r\nBody\r\n", "# Title\n\nBody"} {
+		assert.Equal(t, expected, gemtext.ParseString(text).Lines)
+	}
+	assert.Empty(t, gemtext.ParseString("").Lines)
+}
+
+// TestDocumentRoundTrip tests serializing documents back to gemtext.
+func TestDocumentRoundTrip(t *testing.T) {
+	text := "#Title\r\n" +
+		"Some text\r\n" +
+		"\r\n" +
+		"=>/a  A link \r\n" +
+		"=> /b\r\n" +
+		"=: /c Prompt\r\n" +
+		"*  spaced item\r\n" +
+		">quote\r\n" +
+		"```  alt\r\n" +
+		"  keep   spacing\r\n" +
+		"```\r\n" +
+		"=>\r\n"
+	doc := gemtext.ParseString(text)
+	serialized := doc.String()
+	assert.Equal(t, "# Title\n"+
+		"Some text\n"+
+		"\n"+
+		"=> /a A link\n"+
+		"=> /b\n"+
+		"=: /c Prompt\n"+
+		"* spaced item\n"+
+		"> quote\n"+
+		"```alt\n"+
+		"  keep   spacing\n"+
+		"```\n"+
+		"=>\n", serialized)
+	assert.Equal(t, doc, gemtext.ParseString(serialized))
+
+	var b strings.Builder
+	n, err := doc.WriteTo(&b)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(serialized)), n)
+	assert.Equal(t, serialized, b.String())
+}
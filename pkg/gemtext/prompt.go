@@ -0,0 +1,35 @@
+package gemtext
+
+import (
+	"strings"
+)
+
+// PromptUrls gets the urls of Spartan input prompt lines (`=: url text`).
+// Following one of these asks for input to send to the url.
+func PromptUrls(text string) []string {
+	urls := make([]string, 0)
+	for _, line := range ParseString(text).Lines {
+		if prompt, ok := line.(Prompt); ok {
+			urls = append(urls, prompt.Url)
+		}
+	}
+	return urls
+}
+
+// Preformat wraps plain text in a preformatted block.
+func Preformat(text string) string {
+	lines := strings.Split(strings.TrimSuffix(strings.ReplaceAll(text, "\r\n", "\n"), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = EscapePreformatted(line)
+	}
+	return "```\n" + strings.Join(lines, "\n") + "\n```\n"
+}
+
+// EscapePreformatted indents a line starting with ``` by a space so it does
+// not end the preformatted block it is placed in.
+func EscapePreformatted(line string) string {
+	if strings.HasPrefix(line, "```") {
+		return " " + line
+	}
+	return line
+}
@@ -0,0 +1,27 @@
+package gemtext_test
+
+import (
+	"testing"
+
+	"github.com/jasmaa/hikawa/pkg/gemtext"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPromptUrls tests finding Spartan input prompts outside preformatted blocks.
+func TestPromptUrls(t *testing.T) {
+	text := "# Guestbook\r\n" +
+		"=: /sign Sign the guestbook\r\n" +
+		"=> /entries Read entries\r\n" +
+		"```\r\n" +
+		"=: /not-a-prompt\r\n" +
+		"```\r\n" +
+		"=:spartan://example.org/search"
+	assert.Equal(t, []string{"/sign", "spartan://example.org/search"}, gemtext.PromptUrls(text))
+}
+
+// TestPreformat tests wrapping text in a preformatted block.
+func TestPreformat(t *testing.T) {
+	assert.Equal(t, "```\nline 1\nline 2\n```\n", gemtext.Preformat("line 1\r\nline 2\r\n"))
+	assert.Equal(t, "```\nno newline\n```\n", gemtext.Preformat("no newline"))
+	assert.Equal(t, "```\n ```\n# not a heading\n```\n", gemtext.Preformat("```\n# not a heading\n"))
+}
@@ -0,0 +1,150 @@
+package gemtext
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+)
+
+// RuneWidth gets the number of terminal cells a rune takes. East Asian wide
+// and fullwidth runes take two cells, and combining marks, zero width
+// characters and control characters take none.
+func RuneWidth(r rune) int {
+	switch {
+	case r == 0x200B || r == 0x200C || r == 0x200D || r == 0x2060 || r == 0xFEFF:
+		// Zero width spaces and joiners
+		return 0
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cc, unicode.Cf):
+		return 0
+	case unicode.Is(unicode.Variation_Selector, r):
+		return 0
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// StringWidth gets the number of terminal cells a string takes.
+func StringWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += RuneWidth(r)
+	}
+	return w
+}
+
+// wrapToken is a run of text that is not broken unless it is too long for a line.
+type wrapToken struct {
+	text        string
+	width       int
+	spaceBefore bool
+}
+
+// Wrap breaks text into lines of at most width cells. Lines break at spaces
+// and around East Asian wide characters, and words longer than a line are
+// broken between characters. Combining marks stay with the character before
+// them. Runs of spaces collapse to one. A width of 0 or less does not wrap.
+func Wrap(text string, width int) []string {
+	if width <= 0 {
+		return []string{text}
+	}
+	lines := make([]string, 0)
+	var line strings.Builder
+	lineWidth := 0
+	flush := func() {
+		lines = append(lines, line.String())
+		line.Reset()
+		lineWidth = 0
+	}
+
+	for _, token := range wrapTokens(text) {
+		space := 0
+		if token.spaceBefore && lineWidth > 0 {
+			space = 1
+		}
+		if lineWidth+space+token.width <= width {
+			if space > 0 {
+				line.WriteByte(' ')
+			}
+			line.WriteString(token.text)
+			lineWidth += space + token.width
+			continue
+		}
+		if lineWidth > 0 {
+			flush()
+		}
+		if token.width <= width {
+			line.WriteString(token.text)
+			lineWidth = token.width
+			continue
+		}
+		// Break a word that is longer than a line
+		for _, cluster := range clusters(token.text) {
+			clusterWidth := StringWidth(cluster)
+			if lineWidth+clusterWidth > width && lineWidth > 0 {
+				flush()
+			}
+			line.WriteString(cluster)
+			lineWidth += clusterWidth
+		}
+	}
+	if lineWidth > 0 || len(lines) == 0 {
+		flush()
+	}
+	return lines
+}
+
+// wrapTokens splits text into words and East Asian wide characters.
+func wrapTokens(text string) []wrapToken {
+	tokens := make([]wrapToken, 0)
+	var word strings.Builder
+	wordWidth := 0
+	spaceBefore := false
+	endWord := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, wrapToken{text: word.String(), width: wordWidth, spaceBefore: spaceBefore})
+			word.Reset()
+			wordWidth = 0
+			spaceBefore = false
+		}
+	}
+
+	for _, cluster := range clusters(text) {
+		r, _ := utf8.DecodeRuneInString(cluster)
+		clusterWidth := StringWidth(cluster)
+		switch {
+		case unicode.IsSpace(r):
+			endWord()
+			spaceBefore = true
+		case clusterWidth == 2:
+			endWord()
+			tokens = append(tokens, wrapToken{text: cluster, width: clusterWidth, spaceBefore: spaceBefore})
+			spaceBefore = false
+		default:
+			word.WriteString(cluster)
+			wordWidth += clusterWidth
+		}
+	}
+	endWord()
+	return tokens
+}
+
+// clusters splits text into characters with their combining marks.
+func clusters(text string) []string {
+	result := make([]string, 0, len(text))
+	start := 0
+	for i, r := range text {
+		if i > start && (RuneWidth(r) > 0 || unicode.IsSpace(r)) {
+			result = append(result, text[start:i])
+			start = i
+		}
+	}
+	if start < len(text) {
+		result = append(result, text[start:])
+	}
+	return result
+}
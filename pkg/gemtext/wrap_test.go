@@ -0,0 +1,49 @@
+package gemtext_test
+
+import (
+	"testing"
+
+	"github.com/jasmaa/hikawa/pkg/gemtext"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStringWidth tests counting terminal cells.
+func TestStringWidth(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+	}{
+		{"hello", 5},
+		{"日本語", 6},
+		{"ｆｕｌｌ", 8},
+		{"e\u0301te\u0301", 3},
+		{"a\u200bb", 2},
+		{"ﾊﾝｶｸ", 4},
+		{"", 0},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.width, gemtext.StringWidth(test.text), test.text)
+	}
+}
+
+// TestWrap tests wrapping text to a width.
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		lines []string
+	}{
+		{"the quick brown fox jumps", 10, []string{"the quick", "brown fox", "jumps"}},
+		{"spaces   collapse", 40, []string{"spaces collapse"}},
+		{"unbreakable", 4, []string{"unbr", "eaka", "ble"}},
+		{"日本語のテキスト", 6, []string{"日本語", "のテキ", "スト"}},
+		{"mixed 日本語 text", 8, []string{"mixed 日", "本語", "text"}},
+		{"cafe\u0301 cafe\u0301", 4, []string{"cafe\u0301", "cafe\u0301"}},
+		{"e\u0301e\u0301e\u0301", 2, []string{"e\u0301e\u0301", "e\u0301"}},
+		{"", 10, []string{""}},
+		{"no wrapping at all", 0, []string{"no wrapping at all"}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.lines, gemtext.Wrap(test.text, test.width), test.text)
+	}
+}
@@ -0,0 +1,167 @@
+package gopher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jasmaa/hikawa/internal/rawconn"
+	"github.com/jasmaa/hikawa/pkg/gemini"
+)
+
+// DEFAULT_PORT is the port used when a gopher url has none.
+const DEFAULT_PORT = "70"
+
+// ErrSearchRequired is returned when a search item is requested without a search.
+var ErrSearchRequired = errors.New("gopher: search item needs a search")
+
+// Response is the answer to a gopher request.
+type Response struct {
+	// Item is the requested item.
+	Item Item
+	// Body is the content of the item. Text and menu bodies end before their
+	// terminating "." line.
+	Body io.ReadCloser
+}
+
+// Client requests gopher items.
+type Client struct {
+	// Dialer opens connections. If nil, a net.Dialer is used.
+	Dialer gemini.Dialer
+	// Timeout limits dialing and each read from the server. Zero means no limit.
+	Timeout time.Duration
+}
+
+// MakeClient makes client with default settings.
+func MakeClient() Client {
+	return Client{
+		Timeout: 30 * time.Second,
+	}
+}
+
+// DefaultClient is the client used by Request.
+var DefaultClient = MakeClient()
+
+// Request requests a gopher url using DefaultClient.
+func Request(rawurl string) (*Response, error) {
+	return DefaultClient.RequestContext(context.Background(), rawurl)
+}
+
+// RequestContext requests a gopher url using DefaultClient.
+func RequestContext(ctx context.Context, rawurl string) (*Response, error) {
+	return DefaultClient.RequestContext(ctx, rawurl)
+}
+
+// RequestContext requests a gopher url. The connection is closed when ctx is
+// done. Search items without a search return ErrSearchRequired.
+func (c *Client) RequestContext(ctx context.Context, rawurl string) (*Response, error) {
+	item, search, err := ParseUrl(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if item.Type == ITEM_SEARCH && len(search) == 0 {
+		return nil, ErrSearchRequired
+	}
+
+	line := item.Selector
+	if item.Type == ITEM_SEARCH {
+		line += "\t" + search
+	}
+	body, err := rawconn.Dial(ctx, c.Dialer, c.Timeout, net.JoinHostPort(item.Host, item.Port), []byte(line+"\r\n"))
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &Response{Item: item, Body: body}
+	if item.Type.IsText() {
+		resp.Body = &textBody{reader: bufio.NewReader(body), closer: body}
+	}
+	return resp, nil
+}
+
+// ParseUrl gets the item and search a gopher url points at. The path is the
+// item type followed by the selector, and a search follows a %09 or is
+// given as the query.
+func ParseUrl(rawurl string) (Item, string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return Item{}, "", err
+	}
+	if u.Scheme != "gopher" {
+		return Item{}, "", errors.New("scheme was not gopher")
+	}
+	if len(u.Hostname()) == 0 {
+		return Item{}, "", errors.New("gopher url has no host")
+	}
+	item := Item{Type: ITEM_MENU, Host: u.Hostname(), Port: u.Port()}
+	if len(item.Port) == 0 {
+		item.Port = DEFAULT_PORT
+	}
+
+	rawpath := strings.TrimPrefix(u.EscapedPath(), "/")
+	rawsearch := ""
+	if i := strings.Index(strings.ToLower(rawpath), "%09"); i >= 0 {
+		rawpath, rawsearch = rawpath[:i], rawpath[i+3:]
+	}
+	selector, err := url.PathUnescape(rawpath)
+	if err != nil {
+		return Item{}, "", err
+	}
+	search, err := url.PathUnescape(rawsearch)
+	if err != nil {
+		return Item{}, "", err
+	}
+	if len(selector) > 0 {
+		item.Type = ItemType(selector[0])
+		item.Selector = selector[1:]
+	}
+	if len(u.RawQuery) > 0 {
+		if item.Type == ITEM_SEARCH && len(search) == 0 {
+			if search, err = url.QueryUnescape(u.RawQuery); err != nil {
+				return Item{}, "", err
+			}
+		} else {
+			item.Selector += "?" + u.RawQuery
+		}
+	}
+	return item, search, nil
+}
+
+// textBody reads text up to its terminating "." line and undoes dot-stuffing.
+type textBody struct {
+	reader  *bufio.Reader
+	closer  io.Closer
+	pending []byte
+	err     error
+}
+
+func (b *textBody) Read(p []byte) (int, error) {
+	for len(b.pending) == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		line, err := b.reader.ReadBytes('\n')
+		if bytes.Equal(bytes.TrimRight(line, "\r\n"), []byte(".")) {
+			b.err = io.EOF
+			return 0, io.EOF
+		}
+		if bytes.HasPrefix(line, []byte("..")) {
+			line = line[1:]
+		}
+		b.pending = line
+		b.err = err
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+func (b *textBody) Close() error {
+	return b.closer.Close()
+}
@@ -0,0 +1,145 @@
+package gopher_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jasmaa/hikawa/internal/testserver"
+	"github.com/jasmaa/hikawa/pkg/gopher"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveGopher serves responses by request line and returns the server address.
+// Each request line received is sent on lines.
+func serveGopher(t *testing.T, responses map[string]string, lines chan<- string) (string, string) {
+	addr := testserver.TCP(t, func(conn net.Conn) {
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSuffix(line, "\r\n")
+		if lines != nil {
+			lines <- line
+		}
+		conn.Write([]byte(responses[line]))
+	})
+	host, port, _ := net.SplitHostPort(addr)
+	return host, port
+}
+
+// TestRequestMenu tests requesting a menu.
+func TestRequestMenu(t *testing.T) {
+	host, port := serveGopher(t, map[string]string{
+		"": "iWelcome\t\terror.host\t1\r\n0About\t/about.txt\texample.org\t70\r\n.\r\n",
+	}, nil)
+	resp, err := gopher.Request("gopher://" + net.JoinHostPort(host, port))
+	if assert.Nil(t, err) {
+		defer resp.Body.Close()
+		assert.Equal(t, gopher.ITEM_MENU, resp.Item.Type)
+		items, err := gopher.ParseMenu(resp.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, []gopher.Item{
+			{Type: gopher.ITEM_INFO, Display: "Welcome", Host: "error.host", Port: "1"},
+			{Type: gopher.ITEM_TEXT, Display: "About", Selector: "/about.txt", Host: "example.org", Port: "70"},
+		}, items)
+	}
+}
+
+// TestRequestText tests reading text up to its terminator.
+func TestRequestText(t *testing.T) {
+	host, port := serveGopher(t, map[string]string{
+		"/about.txt": "Hello\r\n..dotted\r\n.\r\nignored\r\n",
+	}, nil)
+	resp, err := gopher.Request("gopher://" + net.JoinHostPort(host, port) + "/0/about.txt")
+	if assert.Nil(t, err) {
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, "Hello\r\n.dotted\r\n", string(data))
+	}
+}
+
+// TestRequestBinary tests reading binaries as they are.
+func TestRequestBinary(t *testing.T) {
+	host, port := serveGopher(t, map[string]string{
+		"/file.bin": "\x00\r\n.\r\n\xff",
+	}, nil)
+	resp, err := gopher.Request("gopher://" + net.JoinHostPort(host, port) + "/9/file.bin")
+	if assert.Nil(t, err) {
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, "\x00\r\n.\r\n\xff", string(data))
+	}
+}
+
+// TestRequestSearch tests sending a search.
+func TestRequestSearch(t *testing.T) {
+	lines := make(chan string, 1)
+	host, port := serveGopher(t, map[string]string{
+		"/search\tgemini protocol": "1Result\t/result\texample.org\t70\r\n.\r\n",
+	}, lines)
+	addr := net.JoinHostPort(host, port)
+
+	_, err := gopher.Request("gopher://" + addr + "/7/search")
+	assert.True(t, errors.Is(err, gopher.ErrSearchRequired))
+
+	resp, err := gopher.Request("gopher://" + addr + "/7/search?gemini%20protocol")
+	if assert.Nil(t, err) {
+		defer resp.Body.Close()
+		assert.Equal(t, "/search\tgemini protocol", <-lines)
+		items, _ := gopher.ParseMenu(resp.Body)
+		assert.Len(t, items, 1)
+	}
+}
+
+// TestRequestCancel tests closing the connection when the context is done.
+func TestRequestCancel(t *testing.T) {
+	addr := testserver.TCP(t, func(conn net.Conn) {
+		io.Copy(io.Discard, conn)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := gopher.MakeClient()
+	resp, err := client.RequestContext(ctx, "gopher://"+addr+"/9/stalled")
+	if assert.Nil(t, err) {
+		cancel()
+		_, err = io.ReadAll(resp.Body)
+		assert.NotNil(t, err)
+	}
+}
+
+// TestParseUrl tests getting items and searches from urls.
+func TestParseUrl(t *testing.T) {
+	tests := []struct {
+		rawurl string
+		item   gopher.Item
+		search string
+	}{
+		{"gopher://example.org", gopher.Item{Type: gopher.ITEM_MENU, Host: "example.org", Port: "70"}, ""},
+		{"gopher://example.org/", gopher.Item{Type: gopher.ITEM_MENU, Host: "example.org", Port: "70"}, ""},
+		{"gopher://example.org:7070/1/phlog", gopher.Item{Type: gopher.ITEM_MENU, Selector: "/phlog", Host: "example.org", Port: "7070"}, ""},
+		{"gopher://example.org/0/a%20b.txt", gopher.Item{Type: gopher.ITEM_TEXT, Selector: "/a b.txt", Host: "example.org", Port: "70"}, ""},
+		{"gopher://example.org/7/v2/vs%09cats", gopher.Item{Type: gopher.ITEM_SEARCH, Selector: "/v2/vs", Host: "example.org", Port: "70"}, "cats"},
+		{"gopher://example.org/7/v2/vs?dogs%20and%20cats", gopher.Item{Type: gopher.ITEM_SEARCH, Selector: "/v2/vs", Host: "example.org", Port: "70"}, "dogs and cats"},
+		{"gopher://example.org/1/cgi?page=2", gopher.Item{Type: gopher.ITEM_MENU, Selector: "/cgi?page=2", Host: "example.org", Port: "70"}, ""},
+		{"gopher://[::1]/9/file", gopher.Item{Type: gopher.ITEM_BINARY, Selector: "/file", Host: "::1", Port: "70"}, ""},
+	}
+	for _, test := range tests {
+		item, search, err := gopher.ParseUrl(test.rawurl)
+		if assert.Nil(t, err, test.rawurl) {
+			assert.Equal(t, test.item, item, test.rawurl)
+			assert.Equal(t, test.search, search, test.rawurl)
+		}
+	}
+
+	for _, rawurl := range []string{"gemini://example.org/", "gopher:///1/"} {
+		_, _, err := gopher.ParseUrl(rawurl)
+		assert.NotNil(t, err, rawurl)
+	}
+}
@@ -0,0 +1,142 @@
+package gopher
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/jasmaa/hikawa/pkg/gemtext"
+)
+
+// ItemType is the type of a gopher item.
+type ItemType byte
+
+const (
+	ITEM_TEXT   ItemType = '0'
+	ITEM_MENU   ItemType = '1'
+	ITEM_ERROR  ItemType = '3'
+	ITEM_SEARCH ItemType = '7'
+	ITEM_BINARY ItemType = '9'
+	ITEM_GIF    ItemType = 'g'
+	ITEM_HTML   ItemType = 'h'
+	ITEM_INFO   ItemType = 'i'
+	ITEM_IMAGE  ItemType = 'I'
+)
+
+// IsMenu checks if an item is answered with a menu.
+func (t ItemType) IsMenu() bool {
+	return t == ITEM_MENU || t == ITEM_SEARCH
+}
+
+// IsText checks if an item is answered with text ending in a lone "." line.
+func (t ItemType) IsText() bool {
+	return t == ITEM_TEXT || t.IsMenu()
+}
+
+// Item is a gopher menu entry.
+type Item struct {
+	Type     ItemType
+	Display  string
+	Selector string
+	Host     string
+	Port     string
+}
+
+// Url gets the url an item links to. Info and error items have no url.
+// HTML items with a "URL:" selector link to that url.
+func (i Item) Url() string {
+	switch i.Type {
+	case ITEM_INFO, ITEM_ERROR:
+		return ""
+	case ITEM_HTML:
+		if strings.HasPrefix(i.Selector, "URL:") {
+			return strings.TrimPrefix(i.Selector, "URL:")
+		}
+	}
+	host := i.Host
+	if len(i.Port) > 0 && i.Port != DEFAULT_PORT {
+		host = net.JoinHostPort(i.Host, i.Port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u := url.URL{
+		Scheme: "gopher",
+		Host:   host,
+		Path:   "/" + string(i.Type) + i.Selector,
+	}
+	return u.String()
+}
+
+// ParseMenu parses a gophermap. Lines without tabs are read as info lines.
+func ParseMenu(r io.Reader) ([]Item, error) {
+	items := make([]Item, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), 64*1024)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "." {
+			break
+		}
+		if !strings.Contains(line, "\t") {
+			items = append(items, Item{Type: ITEM_INFO, Display: line})
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		for len(fields) < 4 {
+			fields = append(fields, "")
+		}
+		item := Item{
+			Display:  fields[0],
+			Selector: fields[1],
+			Host:     fields[2],
+			Port:     strings.TrimSpace(fields[3]),
+		}
+		if len(item.Display) > 0 {
+			item.Type = ItemType(item.Display[0])
+			item.Display = item.Display[1:]
+		} else {
+			item.Type = ITEM_INFO
+		}
+		items = append(items, item)
+	}
+	return items, scanner.Err()
+}
+
+// MenuToGemtext converts menu items to gemtext. Runs of info and error lines
+// are preformatted so text art keeps its layout.
+func MenuToGemtext(items []Item) string {
+	var text strings.Builder
+	isPreformatMode := false
+	for _, item := range items {
+		rawurl := item.Url()
+		if len(rawurl) == 0 {
+			if !isPreformatMode {
+				text.WriteString("```\n")
+				isPreformatMode = true
+			}
+			text.WriteString(gemtext.EscapePreformatted(item.Display))
+			text.WriteString("\n")
+			continue
+		}
+		if isPreformatMode {
+			text.WriteString("```\n")
+			isPreformatMode = false
+		}
+		display := strings.TrimSpace(item.Display)
+		if len(display) == 0 {
+			display = rawurl
+		}
+		text.WriteString("=> " + rawurl + " " + display + "\n")
+	}
+	if isPreformatMode {
+		text.WriteString("```\n")
+	}
+	return text.String()
+}
+
+// TextToGemtext converts a text item to preformatted gemtext.
+func TextToGemtext(text string) string {
+	return gemtext.Preformat(text)
+}
@@ -0,0 +1,98 @@
+package gopher_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jasmaa/hikawa/pkg/gopher"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseMenu tests parsing every supported item type.
+func TestParseMenu(t *testing.T) {
+	menu := strings.Join([]string{
+		"iWelcome to the hole\tfake\t(NULL)\t0",
+		"0About\t/about.txt\texample.org\t70",
+		"1Phlog\t/phlog\texample.org\t70",
+		"7Search\t/search\tsearch.example.org\t7070",
+		"hWebsite\tURL:https://example.org/\texample.org\t70",
+		"gCat\t/cat.gif\texample.org\t70",
+		"IPhoto\t/photo.jpg\texample.org\t70",
+		"9Archive\t/files.zip\texample.org\t70",
+		"3Oops\t\terror.host\t1",
+		"plain line without tabs",
+		".",
+		"1After the end\t/\texample.org\t70",
+	}, "\r\n")
+	items, err := gopher.ParseMenu(strings.NewReader(menu))
+	assert.Nil(t, err)
+	assert.Equal(t, []gopher.Item{
+		{Type: gopher.ITEM_INFO, Display: "Welcome to the hole", Selector: "fake", Host: "(NULL)", Port: "0"},
+		{Type: gopher.ITEM_TEXT, Display: "About", Selector: "/about.txt", Host: "example.org", Port: "70"},
+		{Type: gopher.ITEM_MENU, Display: "Phlog", Selector: "/phlog", Host: "example.org", Port: "70"},
+		{Type: gopher.ITEM_SEARCH, Display: "Search", Selector: "/search", Host: "search.example.org", Port: "7070"},
+		{Type: gopher.ITEM_HTML, Display: "Website", Selector: "URL:https://example.org/", Host: "example.org", Port: "70"},
+		{Type: gopher.ITEM_GIF, Display: "Cat", Selector: "/cat.gif", Host: "example.org", Port: "70"},
+		{Type: gopher.ITEM_IMAGE, Display: "Photo", Selector: "/photo.jpg", Host: "example.org", Port: "70"},
+		{Type: gopher.ITEM_BINARY, Display: "Archive", Selector: "/files.zip", Host: "example.org", Port: "70"},
+		{Type: gopher.ITEM_ERROR, Display: "Oops", Host: "error.host", Port: "1"},
+		{Type: gopher.ITEM_INFO, Display: "plain line without tabs"},
+	}, items)
+}
+
+// TestItemUrl tests making urls for items.
+func TestItemUrl(t *testing.T) {
+	tests := []struct {
+		item   gopher.Item
+		rawurl string
+	}{
+		{gopher.Item{Type: gopher.ITEM_MENU, Selector: "/phlog", Host: "example.org", Port: "70"}, "gopher://example.org/1/phlog"},
+		{gopher.Item{Type: gopher.ITEM_TEXT, Selector: "/a b.txt", Host: "example.org", Port: "7070"}, "gopher://example.org:7070/0/a%20b.txt"},
+		{gopher.Item{Type: gopher.ITEM_SEARCH, Selector: "", Host: "::1", Port: "70"}, "gopher://[::1]/7"},
+		{gopher.Item{Type: gopher.ITEM_HTML, Selector: "URL:https://example.org/", Host: "example.org", Port: "70"}, "https://example.org/"},
+		{gopher.Item{Type: gopher.ITEM_INFO, Display: "text"}, ""},
+		{gopher.Item{Type: gopher.ITEM_ERROR, Display: "error"}, ""},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.rawurl, test.item.Url())
+	}
+}
+
+// TestMenuToGemtext tests converting a menu to gemtext.
+func TestMenuToGemtext(t *testing.T) {
+	items := []gopher.Item{
+		{Type: gopher.ITEM_INFO, Display: " /\\_/\\"},
+		{Type: gopher.ITEM_INFO, Display: "( o.o )"},
+		{Type: gopher.ITEM_MENU, Display: "Phlog", Selector: "/phlog", Host: "example.org", Port: "70"},
+		{Type: gopher.ITEM_SEARCH, Display: "Search", Selector: "/search", Host: "example.org", Port: "70"},
+		{Type: gopher.ITEM_BINARY, Selector: "/files.zip", Host: "example.org", Port: "70"},
+		{Type: gopher.ITEM_ERROR, Display: "Oops"},
+	}
+	assert.Equal(t, "```\n"+
+		" /\\_/\\\n"+
+		"( o.o )\n"+
+		"```\n"+
+		"=> gopher://example.org/1/phlog Phlog\n"+
+		"=> gopher://example.org/7/search Search\n"+
+		"=> gopher://example.org/9/files.zip gopher://example.org/9/files.zip\n"+
+		"```\n"+
+		"Oops\n"+
+		"```\n", gopher.MenuToGemtext(items))
+}
+
+// TestMenuToGemtextFence tests keeping info lines that look like fences preformatted.
+func TestMenuToGemtextFence(t *testing.T) {
+	items := []gopher.Item{
+		{Type: gopher.ITEM_INFO, Display: "```"},
+		{Type: gopher.ITEM_INFO, Display: "=> gemini://example.org/ not a link"},
+	}
+	assert.Equal(t, "```\n"+
+		" ```\n"+
+		"=> gemini://example.org/ not a link\n"+
+		"```\n", gopher.MenuToGemtext(items))
+}
+
+// TestTextToGemtext tests preformatting text items.
+func TestTextToGemtext(t *testing.T) {
+	assert.Equal(t, "```\nline 1\nline 2\n```\n", gopher.TextToGemtext("line 1\r\nline 2\r\n"))
+}
@@ -0,0 +1,81 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/jasmaa/hikawa/internal/capsule"
+)
+
+// FileFetcher fetches local file urls, so capsules can be previewed before
+// they are deployed. .gmi files are text/gemini, other files are typed by
+// extension or sniffing, and directories are gemtext listings.
+type FileFetcher struct{}
+
+// Fetch reads a file url. The url of a directory gets a trailing slash so
+// links in its listing resolve inside it.
+func (f *FileFetcher) Fetch(ctx context.Context, rawurl string) (*Response, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "file" {
+		return nil, errors.New("scheme was not file")
+	}
+	if len(u.Host) > 0 && u.Host != "localhost" {
+		return nil, errors.New("file url is not on this host")
+	}
+	name := localPath(u.Path)
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+		}
+		entries, err := os.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{
+			Url:       u.String(),
+			MediaType: mustParseMediaType("text/gemini; charset=utf-8"),
+			Body:      io.NopCloser(strings.NewReader(capsule.DirectoryIndex(u.Path, entries))),
+		}, nil
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, capsule.SNIFF_LENGTH)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		file.Close()
+		return nil, err
+	}
+	head = head[:n]
+	return &Response{
+		Url:       u.String(),
+		MediaType: fileMediaType(name, head),
+		Body:      &readerBody{Reader: io.MultiReader(bytes.NewReader(head), file), Closer: file},
+	}, nil
+}
+
+// localPath gets the local path of a file url path, e.g. /C:/Users on
+// Windows is C:\Users.
+func localPath(urlPath string) string {
+	if runtime.GOOS == "windows" && len(urlPath) >= 3 && urlPath[0] == '/' && urlPath[2] == ':' {
+		urlPath = urlPath[1:]
+	}
+	return filepath.FromSlash(urlPath)
+}
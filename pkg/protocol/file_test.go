@@ -0,0 +1,74 @@
+package protocol_test
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jasmaa/hikawa/pkg/gemini"
+	"github.com/jasmaa/hikawa/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+// fileUrl makes the file url of a local path.
+func fileUrl(name string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(name)}).String()
+}
+
+// TestFileFetcher tests previewing a local capsule.
+func TestFileFetcher(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "posts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"index.gmi":       "# Home\n=> posts/ Posts\n",
+		"notes.txt":       "plain notes",
+		"unknown":         "GIF89a",
+		"posts/first.gmi": "# First\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fetcher := &protocol.FileFetcher{}
+
+	tests := []struct {
+		name      string
+		mediaType string
+	}{
+		{"index.gmi", "text/gemini"},
+		{"notes.txt", "text/plain"},
+		{"unknown", "image/gif"},
+	}
+	for _, test := range tests {
+		resp, err := fetcher.Fetch(context.Background(), fileUrl(filepath.Join(dir, test.name)))
+		if assert.Nil(t, err, test.name) {
+			assert.Equal(t, test.mediaType, resp.MediaType.String(), test.name)
+			assert.Equal(t, files[test.name], readBody(t, resp), test.name)
+		}
+	}
+
+	// Directory links resolve through NextUrl
+	resp, err := fetcher.Fetch(context.Background(), fileUrl(filepath.Join(dir, "posts")))
+	if assert.Nil(t, err) {
+		assert.Equal(t, fileUrl(filepath.Join(dir, "posts"))+"/", resp.Url)
+		assert.Equal(t, "text/gemini", resp.MediaType.String())
+		assert.Contains(t, readBody(t, resp), "=> first.gmi first.gmi\n")
+
+		nextUrl, err := gemini.NextUrl(resp.Url, "first.gmi")
+		assert.Nil(t, err)
+		resp, err = fetcher.Fetch(context.Background(), nextUrl)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "# First\n", readBody(t, resp))
+		}
+	}
+
+	_, err = fetcher.Fetch(context.Background(), fileUrl(filepath.Join(dir, "missing.gmi")))
+	assert.True(t, os.IsNotExist(err))
+	_, err = fetcher.Fetch(context.Background(), "file://example.org/etc/hosts")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,57 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jasmaa/hikawa/internal/rawconn"
+	"github.com/jasmaa/hikawa/pkg/gemini"
+)
+
+// FINGER_PORT is the port used when a finger url has none.
+const FINGER_PORT = "79"
+
+// FingerFetcher fetches finger urls per RFC 1288. The user is the path of
+// the url, as in finger://example.org/alice, or its userinfo, as in
+// finger://alice@example.org. Without a user the server lists its users.
+type FingerFetcher struct {
+	// Dialer opens connections. If nil, a net.Dialer is used.
+	Dialer gemini.Dialer
+	// Timeout limits dialing and each read. Zero means no limit.
+	Timeout time.Duration
+}
+
+// Fetch sends a finger query and returns the answer as text/plain.
+func (f *FingerFetcher) Fetch(ctx context.Context, rawurl string) (*Response, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "finger" {
+		return nil, errors.New("scheme was not finger")
+	}
+	addr, err := hostAddr(u, FINGER_PORT)
+	if err != nil {
+		return nil, err
+	}
+	user := strings.TrimPrefix(u.Path, "/")
+	if u.User != nil {
+		user = u.User.Username()
+	}
+	if strings.ContainsAny(user, "\r\n") {
+		return nil, errors.New("finger user contains a line break")
+	}
+
+	body, err := rawconn.Dial(ctx, f.Dialer, f.Timeout, addr, []byte(user+"\r\n"))
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Url:       u.String(),
+		MediaType: mustParseMediaType("text/plain; charset=utf-8"),
+		Body:      body,
+	}, nil
+}
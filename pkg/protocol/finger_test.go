@@ -0,0 +1,36 @@
+package protocol_test
+
+import (
+	"bufio"
+	"context"
+	"testing"
+
+	"github.com/jasmaa/hikawa/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFingerFetcher tests querying users.
+func TestFingerFetcher(t *testing.T) {
+	addr := serveTCP(t, func(line string, reader *bufio.Reader) string {
+		return "query: " + line
+	})
+	fetcher := &protocol.FingerFetcher{}
+	tests := []struct {
+		rawurl string
+		body   string
+	}{
+		{"finger://" + addr + "/alice", "query: alice\r\n"},
+		{"finger://bob@" + addr, "query: bob\r\n"},
+		{"finger://" + addr, "query: \r\n"},
+	}
+	for _, test := range tests {
+		resp, err := fetcher.Fetch(context.Background(), test.rawurl)
+		if assert.Nil(t, err, test.rawurl) {
+			assert.Equal(t, "text/plain", resp.MediaType.String())
+			assert.Equal(t, test.body, readBody(t, resp))
+		}
+	}
+
+	_, err := fetcher.Fetch(context.Background(), "finger://"+addr+"/a%0D%0Ab")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,50 @@
+package protocol
+
+import (
+	"context"
+
+	"github.com/jasmaa/hikawa/pkg/gemini"
+)
+
+// GeminiFetcher fetches gemini urls with a Client.
+type GeminiFetcher struct {
+	// Client sends requests. If nil, gemini.DefaultClient is used.
+	Client *gemini.Client
+}
+
+// Fetch navigates to a gemini url, skipping the client cache if ctx was made
+// with WithBypassCache. Input statuses are returned as an *InputError,
+// redirects the client's RedirectPolicy asks about as a *RedirectError and
+// failure statuses as a *gemini.StatusError.
+func (f *GeminiFetcher) Fetch(ctx context.Context, rawurl string) (*Response, error) {
+	client := f.Client
+	if client == nil {
+		client = &gemini.DefaultClient
+	}
+	navigate := client.NavigatePageContext
+	if BypassCache(ctx) {
+		navigate = client.ReloadPageContext
+	}
+	clientResp, err := navigate(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	header := clientResp.Response.Header
+	if header.Status.IsSuccess() {
+		return &Response{
+			Url:       clientResp.Url,
+			MediaType: clientResp.MediaType,
+			Body:      clientResp.Response.Body,
+			Gemini:    clientResp,
+		}, nil
+	}
+	clientResp.Response.Body.Close()
+	if header.Status.IsInput() {
+		return nil, &InputError{Url: clientResp.Url, Prompt: header.Meta}
+	}
+	if clientResp.PendingRedirect != nil {
+		return nil, &RedirectError{Url: clientResp.PendingRedirect.From, Target: clientResp.PendingRedirect.To}
+	}
+	return nil, &gemini.StatusError{Status: header.Status, Meta: header.Meta, Url: clientResp.Url}
+}
@@ -0,0 +1,59 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/jasmaa/hikawa/pkg/gopher"
+)
+
+// GopherFetcher fetches gopher urls. Menus are converted to gemtext and
+// search items without a search return an *InputError.
+type GopherFetcher struct {
+	// Client sends requests. If nil, gopher.DefaultClient is used.
+	Client *gopher.Client
+}
+
+// Fetch requests a gopher url. Menus are returned as text/gemini, and text
+// items as text/plain.
+func (f *GopherFetcher) Fetch(ctx context.Context, rawurl string) (*Response, error) {
+	client := f.Client
+	if client == nil {
+		client = &gopher.DefaultClient
+	}
+	resp, err := client.RequestContext(ctx, rawurl)
+	if errors.Is(err, gopher.ErrSearchRequired) {
+		return nil, &InputError{Url: rawurl, Prompt: "Search"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.Item.Type {
+	case gopher.ITEM_MENU, gopher.ITEM_SEARCH:
+		defer resp.Body.Close()
+		items, err := gopher.ParseMenu(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{
+			Url:       rawurl,
+			MediaType: mustParseMediaType("text/gemini; charset=utf-8"),
+			Body:      io.NopCloser(strings.NewReader(gopher.MenuToGemtext(items))),
+		}, nil
+	case gopher.ITEM_TEXT:
+		return &Response{Url: rawurl, MediaType: mustParseMediaType("text/plain; charset=utf-8"), Body: resp.Body}, nil
+	case gopher.ITEM_GIF:
+		return &Response{Url: rawurl, MediaType: mustParseMediaType("image/gif"), Body: resp.Body}, nil
+	case gopher.ITEM_HTML:
+		return &Response{Url: rawurl, MediaType: mustParseMediaType("text/html"), Body: resp.Body}, nil
+	}
+	mediaType := fileMediaType(path.Base(resp.Item.Selector), nil)
+	if mediaType.Type == "text" && path.Ext(resp.Item.Selector) == "" {
+		mediaType = mustParseMediaType("application/octet-stream")
+	}
+	return &Response{Url: rawurl, MediaType: mediaType, Body: resp.Body}, nil
+}
@@ -0,0 +1,90 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jasmaa/hikawa/internal/rawconn"
+	"github.com/jasmaa/hikawa/pkg/gemini"
+)
+
+// NEX_PORT is the port used when a nex url has none.
+const NEX_PORT = "1900"
+
+// NexFetcher fetches nex urls. Directories, whose paths are empty or end in a
+// slash, are converted to gemtext and other documents are typed by extension.
+type NexFetcher struct {
+	// Dialer opens connections. If nil, a net.Dialer is used.
+	Dialer gemini.Dialer
+	// Timeout limits dialing and each read. Zero means no limit.
+	Timeout time.Duration
+}
+
+// Fetch requests a nex url. Directory listings are returned as text/gemini.
+func (f *NexFetcher) Fetch(ctx context.Context, rawurl string) (*Response, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "nex" {
+		return nil, errors.New("scheme was not nex")
+	}
+	addr, err := hostAddr(u, NEX_PORT)
+	if err != nil {
+		return nil, err
+	}
+	requestPath := strings.TrimPrefix(u.Path, "/")
+	if strings.ContainsAny(requestPath, "\r\n") {
+		return nil, errors.New("nex path contains a line break")
+	}
+
+	body, err := rawconn.Dial(ctx, f.Dialer, f.Timeout, addr, []byte(requestPath+"\n"))
+	if err != nil {
+		return nil, err
+	}
+	if len(requestPath) > 0 && !strings.HasSuffix(requestPath, "/") {
+		return &Response{Url: u.String(), MediaType: fileMediaType(requestPath, nil), Body: body}, nil
+	}
+
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Url:       u.String(),
+		MediaType: mustParseMediaType("text/gemini; charset=utf-8"),
+		Body:      io.NopCloser(strings.NewReader(NexDirectoryToGemtext(string(data)))),
+	}, nil
+}
+
+// NexDirectoryToGemtext converts a nex directory listing to gemtext. Link
+// lines are kept and runs of other lines are preformatted.
+func NexDirectoryToGemtext(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if len(text) == 0 {
+		return ""
+	}
+	var gemtext strings.Builder
+	isPreformatMode := false
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		isLink := strings.HasPrefix(line, "=>")
+		if isLink && isPreformatMode {
+			gemtext.WriteString("```\n")
+			isPreformatMode = false
+		} else if !isLink && !isPreformatMode {
+			gemtext.WriteString("```\n")
+			isPreformatMode = true
+		}
+		gemtext.WriteString(line)
+		gemtext.WriteString("\n")
+	}
+	if isPreformatMode {
+		gemtext.WriteString("```\n")
+	}
+	return gemtext.String()
+}
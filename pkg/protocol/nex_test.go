@@ -0,0 +1,43 @@
+package protocol_test
+
+import (
+	"bufio"
+	"context"
+	"testing"
+
+	"github.com/jasmaa/hikawa/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNexFetcher tests requesting documents and directories.
+func TestNexFetcher(t *testing.T) {
+	requests := make(chan string, 1)
+	addr := serveTCP(t, func(line string, reader *bufio.Reader) string {
+		requests <- line
+		if line == "notes.txt\n" {
+			return "# not a heading"
+		}
+		return "Welcome\n=> notes.txt Notes\n=> nex://other.example/ Elsewhere\n"
+	})
+	fetcher := &protocol.NexFetcher{}
+
+	resp, err := fetcher.Fetch(context.Background(), "nex://"+addr+"/notes.txt")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "notes.txt\n", <-requests)
+		assert.Equal(t, "text/plain", resp.MediaType.String())
+		assert.Equal(t, "# not a heading", readBody(t, resp))
+	}
+
+	resp, err = fetcher.Fetch(context.Background(), "nex://"+addr)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "\n", <-requests)
+		assert.Equal(t, "text/gemini", resp.MediaType.String())
+		assert.Equal(t, "```\nWelcome\n```\n=> notes.txt Notes\n=> nex://other.example/ Elsewhere\n", readBody(t, resp))
+	}
+}
+
+// TestNexDirectoryToGemtext tests preformatting text between links.
+func TestNexDirectoryToGemtext(t *testing.T) {
+	assert.Equal(t, "=> a/ a\n```\n  ~~~\n# art\n```\n=> b.txt b\n", protocol.NexDirectoryToGemtext("=> a/ a\r\n  ~~~\r\n# art\r\n=> b.txt b\r\n"))
+	assert.Equal(t, "", protocol.NexDirectoryToGemtext(""))
+}
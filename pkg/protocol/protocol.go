@@ -0,0 +1,189 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jasmaa/hikawa/internal/capsule"
+	"github.com/jasmaa/hikawa/pkg/gemini"
+)
+
+// ErrUnsupportedScheme is returned when no fetcher is registered for a scheme.
+var ErrUnsupportedScheme = errors.New("unsupported scheme")
+
+// Response is a fetched resource in the same shape for every protocol.
+type Response struct {
+	// Url is the url of the resource after any redirects.
+	Url string
+	// MediaType is the media type of Body.
+	MediaType gemini.MediaType
+	// Body is the content of the resource. It must be closed.
+	Body io.ReadCloser
+	// Gemini is the client response for gemini urls, with details like the
+	// identity presented and whether it was cached. It is nil for other protocols.
+	Gemini *gemini.ClientResponse
+}
+
+// InputError is returned when a url needs input before it can be fetched.
+// The input is sent by fetching Url with the input as its query.
+type InputError struct {
+	Url    string
+	Prompt string
+}
+
+func (e *InputError) Error() string {
+	return fmt.Sprintf("%s needs input: %s", e.Url, e.Prompt)
+}
+
+// RedirectError is returned when a redirect to Target needs to be confirmed
+// before it is followed.
+type RedirectError struct {
+	Url    string
+	Target string
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("redirect from %s to %s was not followed", e.Url, e.Target)
+}
+
+// ServerError is returned when a server answers with an error.
+type ServerError struct {
+	Url     string
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Url, e.Message)
+}
+
+// Fetcher fetches urls of a scheme.
+type Fetcher interface {
+	Fetch(ctx context.Context, rawurl string) (*Response, error)
+}
+
+// FetcherFunc is a function used as a Fetcher.
+type FetcherFunc func(ctx context.Context, rawurl string) (*Response, error)
+
+// Fetch calls f(ctx, rawurl).
+func (f FetcherFunc) Fetch(ctx context.Context, rawurl string) (*Response, error) {
+	return f(ctx, rawurl)
+}
+
+// bypassCacheKey is the context key set by WithBypassCache.
+type bypassCacheKey struct{}
+
+// WithBypassCache makes a context asking fetchers to skip their caches, e.g.
+// to reload a page.
+func WithBypassCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+// BypassCache checks if ctx asks fetchers to skip their caches.
+func BypassCache(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassCacheKey{}).(bool)
+	return bypass
+}
+
+// Registry holds the fetcher for each scheme.
+type Registry struct {
+	mu       sync.RWMutex
+	fetchers map[string]Fetcher
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		fetchers: make(map[string]Fetcher),
+	}
+}
+
+// NewDefaultRegistry creates a Registry with the fetchers for every
+// supported protocol, using the default Gemini and gopher clients.
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register("gemini", &GeminiFetcher{Client: &gemini.DefaultClient})
+	r.Register("gopher", &GopherFetcher{})
+	r.Register("finger", &FingerFetcher{Timeout: DEFAULT_TIMEOUT})
+	r.Register("spartan", &SpartanFetcher{Timeout: DEFAULT_TIMEOUT})
+	r.Register("nex", &NexFetcher{Timeout: DEFAULT_TIMEOUT})
+	r.Register("file", &FileFetcher{})
+	return r
+}
+
+// DefaultRegistry is the registry used by Fetch.
+var DefaultRegistry = NewDefaultRegistry()
+
+// Fetch fetches a url using DefaultRegistry.
+func Fetch(ctx context.Context, rawurl string) (*Response, error) {
+	return DefaultRegistry.Fetch(ctx, rawurl)
+}
+
+// Register sets the fetcher for a scheme, replacing any fetcher already set.
+func (r *Registry) Register(scheme string, fetcher Fetcher) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fetchers[strings.ToLower(scheme)] = fetcher
+}
+
+// Lookup gets the fetcher for a scheme.
+func (r *Registry) Lookup(scheme string) (Fetcher, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fetcher, ok := r.fetchers[strings.ToLower(scheme)]
+	return fetcher, ok
+}
+
+// Fetch fetches a url with the fetcher for its scheme.
+func (r *Registry) Fetch(ctx context.Context, rawurl string) (*Response, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	fetcher, ok := r.Lookup(u.Scheme)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedScheme, u.Scheme)
+	}
+	return fetcher.Fetch(ctx, rawurl)
+}
+
+// DEFAULT_TIMEOUT limits dialing and each read for the default fetchers.
+const DEFAULT_TIMEOUT = 30 * time.Second
+
+// hostAddr gets the address to dial for a url, using defaultPort if it has none.
+func hostAddr(u *url.URL, defaultPort string) (string, error) {
+	if len(u.Hostname()) == 0 {
+		return "", fmt.Errorf("%s url has no host", u.Scheme)
+	}
+	port := u.Port()
+	if len(port) == 0 {
+		port = defaultPort
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// fileMediaType gets the media type of a file from its name and first bytes
+// with capsule.MediaType. Files without a known extension or head are
+// text/plain.
+func fileMediaType(name string, head []byte) gemini.MediaType {
+	mediaType, err := gemini.ParseMediaType(capsule.MediaType(name, head))
+	if err != nil {
+		mediaType = mustParseMediaType("application/octet-stream")
+	}
+	return mediaType
+}
+
+// mustParseMediaType parses a media type known to be valid.
+func mustParseMediaType(meta string) gemini.MediaType {
+	mediaType, err := gemini.ParseMediaType(meta)
+	if err != nil {
+		panic(err)
+	}
+	return mediaType
+}
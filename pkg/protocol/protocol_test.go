@@ -0,0 +1,93 @@
+package protocol_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/jasmaa/hikawa/internal/testserver"
+	"github.com/jasmaa/hikawa/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+// request is a request received by serveTCP.
+type request struct {
+	line string
+	data string
+}
+
+// serveTCP answers each connection with respond and returns the server address.
+// respond gets the first line of the request and a reader for the rest.
+func serveTCP(t *testing.T, respond func(line string, reader *bufio.Reader) string) string {
+	return testserver.TCP(t, func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		conn.Write([]byte(respond(line, reader)))
+	})
+}
+
+// readBody reads and closes a response body.
+func readBody(t *testing.T, resp *protocol.Response) string {
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+	return string(data)
+}
+
+// TestRegistry tests fetching with the fetcher registered for a scheme.
+func TestRegistry(t *testing.T) {
+	registry := protocol.NewRegistry()
+	registry.Register("Test", protocol.FetcherFunc(func(ctx context.Context, rawurl string) (*protocol.Response, error) {
+		return &protocol.Response{Url: rawurl}, nil
+	}))
+	resp, err := registry.Fetch(context.Background(), "test://example.org/")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "test://example.org/", resp.Url)
+	}
+	_, ok := registry.Lookup("TEST")
+	assert.True(t, ok)
+
+	_, err = registry.Fetch(context.Background(), "other://example.org/")
+	assert.True(t, errors.Is(err, protocol.ErrUnsupportedScheme))
+}
+
+// TestBypassCache tests asking fetchers to skip their caches.
+func TestBypassCache(t *testing.T) {
+	assert.False(t, protocol.BypassCache(context.Background()))
+	assert.True(t, protocol.BypassCache(protocol.WithBypassCache(context.Background())))
+}
+
+// TestDefaultRegistry tests registering every supported protocol.
+func TestDefaultRegistry(t *testing.T) {
+	for _, scheme := range []string{"gemini", "gopher", "finger", "spartan", "nex", "file"} {
+		_, ok := protocol.DefaultRegistry.Lookup(scheme)
+		assert.True(t, ok, scheme)
+	}
+}
+
+// TestGopherFetcher tests converting gopher menus and prompting for searches.
+func TestGopherFetcher(t *testing.T) {
+	addr := serveTCP(t, func(line string, reader *bufio.Reader) string {
+		return "0About\t/about.txt\texample.org\t70\r\n.\r\n"
+	})
+	fetcher := &protocol.GopherFetcher{}
+	resp, err := fetcher.Fetch(context.Background(), "gopher://"+addr+"/1/")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "text/gemini", resp.MediaType.String())
+		assert.Equal(t, "=> gopher://example.org/0/about.txt About\n", readBody(t, resp))
+	}
+
+	_, err = fetcher.Fetch(context.Background(), "gopher://"+addr+"/7/search")
+	var inputErr *protocol.InputError
+	if assert.True(t, errors.As(err, &inputErr)) {
+		assert.Equal(t, "gopher://"+addr+"/7/search", inputErr.Url)
+	}
+}
@@ -0,0 +1,153 @@
+package protocol
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jasmaa/hikawa/internal/rawconn"
+	"github.com/jasmaa/hikawa/pkg/gemini"
+)
+
+// SPARTAN_PORT is the port used when a spartan url has none.
+const SPARTAN_PORT = "300"
+
+// MAX_SPARTAN_REDIRECTS is how many redirects are followed before giving up.
+const MAX_SPARTAN_REDIRECTS = 5
+
+// maxSpartanHeaderLength limits the response header, excluding CRLF.
+const maxSpartanHeaderLength = 1024
+
+// SpartanFetcher fetches spartan urls. The query of a url, which is set when
+// following an `=:` input prompt line, is sent as the data block.
+type SpartanFetcher struct {
+	// Dialer opens connections. If nil, a net.Dialer is used.
+	Dialer gemini.Dialer
+	// Timeout limits dialing and each read. Zero means no limit.
+	Timeout time.Duration
+}
+
+// Fetch requests a spartan url, following redirects. Client and server
+// errors are returned as a *ServerError.
+func (f *SpartanFetcher) Fetch(ctx context.Context, rawurl string) (*Response, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i <= MAX_SPARTAN_REDIRECTS; i++ {
+		resp, redirect, err := f.request(ctx, u)
+		if err != nil || redirect == nil {
+			return resp, err
+		}
+		u = redirect
+	}
+	return nil, fmt.Errorf("stopped after %d redirects", MAX_SPARTAN_REDIRECTS)
+}
+
+// request sends a single spartan request. A redirect is returned as the url to request next.
+func (f *SpartanFetcher) request(ctx context.Context, u *url.URL) (*Response, *url.URL, error) {
+	if u.Scheme != "spartan" {
+		return nil, nil, errors.New("scheme was not spartan")
+	}
+	addr, err := hostAddr(u, SPARTAN_PORT)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Spartan queries are only percent-encoded, so + is kept as it is
+	data, err := url.PathUnescape(u.RawQuery)
+	if err != nil {
+		return nil, nil, err
+	}
+	requestPath := u.EscapedPath()
+	if len(requestPath) == 0 {
+		requestPath = "/"
+	}
+
+	request := fmt.Sprintf("%s %s %d\r\n%s", u.Hostname(), requestPath, len(data), data)
+	body, err := rawconn.Dial(ctx, f.Dialer, f.Timeout, addr, []byte(request))
+	if err != nil {
+		return nil, nil, err
+	}
+	reader := bufio.NewReader(body)
+	status, meta, err := readSpartanHeader(reader)
+	if err != nil {
+		body.Close()
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		return nil, nil, err
+	}
+
+	switch status {
+	case 2:
+		mediaType, err := gemini.ParseMediaType(meta)
+		if err != nil {
+			body.Close()
+			return nil, nil, err
+		}
+		return &Response{
+			Url:       u.String(),
+			MediaType: mediaType,
+			Body:      &readerBody{Reader: reader, Closer: body},
+		}, nil, nil
+	case 3:
+		body.Close()
+		ref, err := url.Parse(meta)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, u.ResolveReference(ref), nil
+	default:
+		body.Close()
+		return nil, nil, &ServerError{Url: u.String(), Message: meta}
+	}
+}
+
+// readSpartanHeader reads `<STATUS><SPACE><META><CR><LF>`.
+func readSpartanHeader(reader *bufio.Reader) (int, string, error) {
+	line := make([]byte, 0, 64)
+	for {
+		c, err := reader.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, "", err
+		}
+		if c == '\n' {
+			break
+		}
+		if len(line) > maxSpartanHeaderLength {
+			return 0, "", errors.New("spartan response header too long")
+		}
+		line = append(line, c)
+	}
+	if len(line) < 2 || line[len(line)-1] != '\r' {
+		return 0, "", errors.New("spartan response header not terminated by CRLF")
+	}
+	line = line[:len(line)-1]
+
+	status, err := strconv.Atoi(string(line[:1]))
+	if err != nil || status < 2 || status > 5 {
+		return 0, "", fmt.Errorf("invalid spartan status %q", line[:1])
+	}
+	if len(line) > 1 && line[1] != ' ' {
+		return 0, "", errors.New("malformed spartan response header")
+	}
+	meta := ""
+	if len(line) > 2 {
+		meta = string(line[2:])
+	}
+	return status, meta, nil
+}
+
+// readerBody reads from a Reader and closes a Closer.
+type readerBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,113 @@
+package protocol_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jasmaa/hikawa/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveSpartan answers spartan requests with respond and sends each request on requests.
+func serveSpartan(t *testing.T, requests chan<- request, respond func(requestPath string) string) string {
+	return serveTCP(t, func(line string, reader *bufio.Reader) string {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return "4 bad request\r\n"
+		}
+		size, _ := strconv.Atoi(fields[2])
+		data := make([]byte, size)
+		io.ReadFull(reader, data)
+		if requests != nil {
+			requests <- request{line: line, data: string(data)}
+		}
+		return respond(fields[1])
+	})
+}
+
+// TestSpartanFetcher tests requesting a page.
+func TestSpartanFetcher(t *testing.T) {
+	requests := make(chan request, 1)
+	addr := serveSpartan(t, requests, func(requestPath string) string {
+		return "2 text/gemini; lang=en\r\n=: /sign Sign the guestbook\n"
+	})
+	resp, err := (&protocol.SpartanFetcher{}).Fetch(context.Background(), "spartan://"+addr)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "text/gemini", resp.MediaType.String())
+		assert.Equal(t, "en", resp.MediaType.Params["lang"])
+		assert.Equal(t, "=: /sign Sign the guestbook\n", readBody(t, resp))
+	}
+	assert.Equal(t, request{line: "127.0.0.1 / 0\r\n"}, <-requests)
+}
+
+// TestSpartanFetcherInput tests sending the query as the data block.
+func TestSpartanFetcherInput(t *testing.T) {
+	requests := make(chan request, 1)
+	addr := serveSpartan(t, requests, func(requestPath string) string {
+		return "2 text/plain\r\nthanks"
+	})
+	resp, err := (&protocol.SpartanFetcher{}).Fetch(context.Background(), "spartan://"+addr+"/sign?hello%20there")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "thanks", readBody(t, resp))
+	}
+	assert.Equal(t, request{line: "127.0.0.1 /sign 11\r\n", data: "hello there"}, <-requests)
+
+	resp, err = (&protocol.SpartanFetcher{}).Fetch(context.Background(), "spartan://"+addr+"/calc?1+1")
+	if assert.Nil(t, err) {
+		readBody(t, resp)
+	}
+	assert.Equal(t, request{line: "127.0.0.1 /calc 3\r\n", data: "1+1"}, <-requests)
+}
+
+// TestSpartanFetcherRedirect tests following redirects on the same host.
+func TestSpartanFetcherRedirect(t *testing.T) {
+	addr := serveSpartan(t, nil, func(requestPath string) string {
+		switch requestPath {
+		case "/old":
+			return "3 /new\r\n"
+		case "/loop":
+			return "3 /loop\r\n"
+		}
+		return "2 text/gemini\r\n" + requestPath
+	})
+	fetcher := &protocol.SpartanFetcher{}
+	resp, err := fetcher.Fetch(context.Background(), "spartan://"+addr+"/old?ignored")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "spartan://"+addr+"/new", resp.Url)
+		assert.Equal(t, "/new", readBody(t, resp))
+	}
+	_, err = fetcher.Fetch(context.Background(), "spartan://"+addr+"/loop")
+	assert.NotNil(t, err)
+}
+
+// TestSpartanFetcherErrors tests returning error statuses and malformed headers.
+func TestSpartanFetcherErrors(t *testing.T) {
+	addr := serveSpartan(t, nil, func(requestPath string) string {
+		switch requestPath {
+		case "/missing":
+			return "4 not found\r\n"
+		case "/broken":
+			return "5 server error\r\n"
+		case "/status":
+			return "9 what\r\n"
+		}
+		return "2 text/gemini\n"
+	})
+	fetcher := &protocol.SpartanFetcher{}
+	for requestPath, message := range map[string]string{"/missing": "not found", "/broken": "server error"} {
+		_, err := fetcher.Fetch(context.Background(), "spartan://"+addr+requestPath)
+		var serverErr *protocol.ServerError
+		if assert.True(t, errors.As(err, &serverErr), requestPath) {
+			assert.Equal(t, message, serverErr.Message)
+		}
+	}
+	for _, requestPath := range []string{"/status", "/lf"} {
+		_, err := fetcher.Fetch(context.Background(), "spartan://"+addr+requestPath)
+		assert.NotNil(t, err, requestPath)
+	}
+}
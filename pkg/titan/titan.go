@@ -0,0 +1,97 @@
+package titan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/jasmaa/hikawa/pkg/gemini"
+)
+
+// DEFAULT_MIME is the media type Titan servers assume when none is given.
+const DEFAULT_MIME = "text/gemini"
+
+// Upload uploads the data read from r to a titan url using gemini.DefaultClient.
+func Upload(rawurl string, mime string, token string, r io.Reader) (*gemini.Response, error) {
+	return UploadContext(context.Background(), &gemini.DefaultClient, rawurl, mime, token, r)
+}
+
+// UploadContext uploads the data read from r to a titan url with client, so
+// the client's known hosts and identities are used. rawurl may be a titan or
+// gemini url and any upload parameters on it are replaced. An empty mime
+// defaults to DEFAULT_MIME and an empty token is left out. Failure
+// statuses are returned as a *gemini.StatusError.
+func UploadContext(ctx context.Context, client *gemini.Client, rawurl string, mime string, token string, r io.Reader) (*gemini.Response, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	uploadUrl, err := UploadUrl(rawurl, mime, token, int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.RequestBodyContext(ctx, uploadUrl, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Header.Status.IsFailure() {
+		resp.Body.Close()
+		return nil, &gemini.StatusError{
+			Status: resp.Header.Status,
+			Meta:   resp.Header.Meta,
+			Url:    uploadUrl,
+		}
+	}
+	return resp, nil
+}
+
+// UploadUrl makes the titan url to upload size bytes to the page at rawurl.
+func UploadUrl(rawurl string, mime string, token string, size int64) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "titan" && u.Scheme != "gemini" {
+		return "", errors.New("scheme was not titan or gemini")
+	}
+	if size < 0 {
+		return "", errors.New("size must not be negative")
+	}
+	if len(mime) == 0 {
+		mime = DEFAULT_MIME
+	}
+
+	u.Scheme = "titan"
+	u.Path, _, _ = strings.Cut(u.Path, ";")
+	if len(u.Path) == 0 {
+		u.Path = "/"
+	}
+	u.RawPath = ""
+	u.RawQuery = ""
+	u.Fragment = ""
+	params := fmt.Sprintf(";mime=%s;size=%d", mime, size)
+	if len(token) > 0 {
+		params += ";token=" + url.PathEscape(token)
+	}
+	return u.String() + params, nil
+}
+
+// GeminiUrl gets the gemini url of the page a titan url uploads to.
+func GeminiUrl(rawurl string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "titan" {
+		return "", errors.New("scheme was not titan")
+	}
+	u.Scheme = "gemini"
+	u.Path, _, _ = strings.Cut(u.Path, ";")
+	u.RawPath = ""
+	return u.String(), nil
+}
@@ -0,0 +1,142 @@
+package titan_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jasmaa/hikawa/internal/testcert"
+	"github.com/jasmaa/hikawa/internal/testserver"
+	"github.com/jasmaa/hikawa/pkg/gemini"
+	"github.com/jasmaa/hikawa/pkg/titan"
+	"github.com/stretchr/testify/assert"
+)
+
+// upload is an upload received by serveTitan.
+type upload struct {
+	requestUrl  string
+	body        string
+	fingerprint string
+}
+
+// serveTitan serves Titan uploads, sending each one on uploads and answering with status.
+func serveTitan(t *testing.T, status string, uploads chan<- upload) string {
+	return testserver.TLS(t, testcert.New(t, time.Now().Add(time.Hour)), func(conn *tls.Conn) {
+		reader := bufio.NewReader(conn)
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		requestUrl := strings.TrimSuffix(line, "\r\n")
+		var size int
+		for _, param := range strings.Split(requestUrl, ";")[1:] {
+			if strings.HasPrefix(param, "size=") {
+				size, _ = strconv.Atoi(strings.TrimPrefix(param, "size="))
+			}
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return
+		}
+		received := upload{requestUrl: requestUrl, body: string(data)}
+		if certs := conn.ConnectionState().PeerCertificates; len(certs) > 0 {
+			sum := sha256.Sum256(certs[0].Raw)
+			received.fingerprint = hex.EncodeToString(sum[:])
+		}
+		uploads <- received
+		conn.Write([]byte(status))
+	})
+}
+
+// TestUpload tests uploading a page.
+func TestUpload(t *testing.T) {
+	uploads := make(chan upload, 1)
+	addr := serveTitan(t, "30 gemini://localhost/page\r\n", uploads)
+	client := gemini.MakeClient()
+	resp, err := titan.UploadContext(context.Background(), &client, "titan://"+addr+"/page", "text/gemini", "secret", strings.NewReader("# Hello\n"))
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, gemini.STATUS_REDIRECT_TEMPORARY, resp.Header.Status)
+		assert.Equal(t, "gemini://localhost/page", resp.Header.Meta)
+	}
+	received := <-uploads
+	assert.Equal(t, fmt.Sprintf("titan://%s/page;mime=text/gemini;size=8;token=secret", addr), received.requestUrl)
+	assert.Equal(t, "# Hello\n", received.body)
+	assert.Equal(t, "", received.fingerprint)
+}
+
+// TestUploadIdentity tests presenting the identity bound to the gemini url of the page.
+func TestUploadIdentity(t *testing.T) {
+	uploads := make(chan upload, 1)
+	addr := serveTitan(t, "20 text/gemini\r\n", uploads)
+	client := gemini.MakeClient()
+	identity, err := gemini.NewIdentity("editor", gemini.KEY_ALGORITHM_ECDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Identities.Add(identity)
+	client.Identities.Bind("editor", "gemini://"+addr+"/")
+
+	resp, err := titan.UploadContext(context.Background(), &client, "gemini://"+addr+"/wiki/page.gmi", "", "", strings.NewReader("text"))
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+	}
+	received := <-uploads
+	assert.Equal(t, fmt.Sprintf("titan://%s/wiki/page.gmi;mime=text/gemini;size=4", addr), received.requestUrl)
+	assert.Equal(t, identity.Fingerprint(), received.fingerprint)
+}
+
+// TestUploadFailure tests returning a failure status as a StatusError.
+func TestUploadFailure(t *testing.T) {
+	uploads := make(chan upload, 1)
+	addr := serveTitan(t, "59 token is wrong\r\n", uploads)
+	client := gemini.MakeClient()
+	_, err := titan.UploadContext(context.Background(), &client, "titan://"+addr+"/page", "", "bad", strings.NewReader(""))
+	var statusErr *gemini.StatusError
+	if assert.True(t, errors.As(err, &statusErr)) {
+		assert.Equal(t, gemini.STATUS_BAD_REQUEST, statusErr.Status)
+		assert.Equal(t, "token is wrong", statusErr.Meta)
+	}
+}
+
+// TestUploadUrl tests making upload urls.
+func TestUploadUrl(t *testing.T) {
+	tests := []struct {
+		rawurl    string
+		mime      string
+		token     string
+		size      int64
+		uploadUrl string
+	}{
+		{"titan://example.org/page", "text/plain", "", 10, "titan://example.org/page;mime=text/plain;size=10"},
+		{"gemini://example.org/page.gmi", "", "abc", 3, "titan://example.org/page.gmi;mime=text/gemini;size=3;token=abc"},
+		{"titan://example.org/page;mime=text/plain;size=1", "", "", 2, "titan://example.org/page;mime=text/gemini;size=2"},
+		{"gemini://example.org", "", "", 0, "titan://example.org/;mime=text/gemini;size=0"},
+		{"gemini://example.org/page?q#frag", "", "a;b", 1, "titan://example.org/page;mime=text/gemini;size=1;token=a%3Bb"},
+	}
+	for _, test := range tests {
+		uploadUrl, err := titan.UploadUrl(test.rawurl, test.mime, test.token, test.size)
+		if assert.Nil(t, err, test.rawurl) {
+			assert.Equal(t, test.uploadUrl, uploadUrl, test.rawurl)
+		}
+	}
+	_, err := titan.UploadUrl("https://example.org/", "", "", 1)
+	assert.NotNil(t, err)
+}
+
+// TestGeminiUrl tests getting the page a titan url uploads to.
+func TestGeminiUrl(t *testing.T) {
+	geminiUrl, err := titan.GeminiUrl("titan://example.org/page.gmi;mime=text/gemini;size=3")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "gemini://example.org/page.gmi", geminiUrl)
+	}
+}
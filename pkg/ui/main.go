@@ -1,27 +1,66 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"image/color"
+	"io"
 	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
 
 	g "github.com/AllenDang/giu"
+	findfont "github.com/AllenDang/go-findfont"
 	"github.com/jasmaa/hikawa/pkg/browsing"
 	"github.com/jasmaa/hikawa/pkg/gemini"
 	"github.com/jasmaa/hikawa/pkg/gemtext"
+	"github.com/jasmaa/hikawa/pkg/protocol"
+	"github.com/jasmaa/hikawa/pkg/titan"
 )
 
 var (
 	searchText              string
 	inputText               string
 	content                 string
+	contentBlocks           []gemtext.ImguiBlock
+	monospaceFont           *g.FontInfo
 	isBackButtonDisabled    bool
 	isForwardButtonDisabled bool
 	isSearchButtonDisabled  bool
+	isLoading               bool
+	stopLoading             context.CancelFunc
+	loadingDone             chan struct{}
 	isInputMode             bool
+	untrustedHost           string
+	pendingRedirect         string
+	untrustedCertificate    gemini.KnownHost
+	isIdentityMode          bool
+	identityName            string
+	identityIndex           int32
+	activeIdentity          string
+	isCached                bool
+	pageSource              string
+	isEditMode              bool
+	editText                string
+	editToken               string
+	editError               string
+	promptUrls              map[string]bool
+	exportMessage           string
+	protocols               *protocol.Registry
 	client                  gemini.Client
 	history                 browsing.History
 )
 
+// monospaceFonts are the font files tried in order for preformatted text.
+var monospaceFonts = []string{"consola.ttf", "Menlo.ttc", "DejaVuSansMono.ttf", "LiberationMono-Regular.ttf", "cour.ttf"}
+
+// quoteColor is the text color of quotes.
+var quoteColor = color.RGBA{R: 160, G: 170, B: 185, A: 255}
+
 func init() {
 	searchText = "gemini://gemini.circumlunar.space/"
 	isBackButtonDisabled = true
@@ -29,16 +68,40 @@ func init() {
 	isSearchButtonDisabled = false
 	isInputMode = false
 	client = gemini.MakeClient()
+	// New hosts are trusted with the Trust certificate button
+	client.TrustNewHosts = false
+	client.OnRetry = onRetry
+	for _, name := range monospaceFonts {
+		if _, err := findfont.Find(name); err == nil {
+			monospaceFont = g.AddFont(name, 14)
+			break
+		}
+	}
+	if configDir, err := os.UserConfigDir(); err == nil {
+		knownHosts, err := gemini.OpenFileKnownHosts(filepath.Join(configDir, "hikawa", "known_hosts"))
+		if err == nil {
+			client.KnownHosts = knownHosts
+		}
+		identities, err := gemini.OpenFileIdentities(filepath.Join(configDir, "hikawa", "identities"))
+		if err == nil {
+			client.Identities = identities
+		}
+	}
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		cache, err := gemini.OpenResponseCache(filepath.Join(cacheDir, "hikawa", "responses"), 32<<20, 24*time.Hour)
+		if err == nil {
+			client.Cache = cache
+		}
+	}
+	protocols = protocol.NewDefaultRegistry()
+	protocols.Register("gemini", &protocol.GeminiFetcher{Client: &client})
 }
 
 func onSubmitSearch() {
-	setLoading()
-	go func() {
-		newUrl := navigatePage(searchText, true)
-		searchText = newUrl
-		setNavigationButtons()
-		g.Update()
-	}()
+	rawurl := searchText
+	startLoading(func(ctx context.Context) string {
+		return navigatePage(ctx, rawurl, true, false)
+	})
 }
 
 func onContentMetaClicked(meta string) {
@@ -57,16 +120,21 @@ func onContentMetaClicked(meta string) {
 	}
 
 	targetScheme := parsedTargetUrl.Scheme
+	if targetScheme == "file" && !strings.HasPrefix(strings.ToLower(currentUrl), "file:") {
+		// Only local pages and the address bar can open local files
+		return
+	}
 	if targetScheme == "http" || targetScheme == "https" {
 		g.OpenURL(targetUrl)
+	} else if promptUrls[meta] {
+		// Spartan input prompts ask for the data to send first
+		searchText = targetUrl
+		content = ""
+		isInputMode = true
 	} else {
-		setLoading()
-		go func() {
-			newUrl := navigatePage(targetUrl, true)
-			searchText = newUrl
-			setNavigationButtons()
-			g.Update()
-		}()
+		startLoading(func(ctx context.Context) string {
+			return navigatePage(ctx, targetUrl, true, false)
+		})
 	}
 }
 
@@ -76,14 +144,21 @@ func onBackButtonPressed() {
 		return
 	}
 
-	setLoading()
-	go func() {
-		currentUrl, _ := history.GetCurrentUrl()
-		newUrl := navigatePage(currentUrl, false)
-		searchText = newUrl
-		setNavigationButtons()
-		g.Update()
-	}()
+	currentUrl, _ := history.GetCurrentUrl()
+	startLoading(func(ctx context.Context) string {
+		return navigatePage(ctx, currentUrl, false, false)
+	})
+}
+
+func onReloadButtonPressed() {
+	currentUrl, err := history.GetCurrentUrl()
+	if err != nil {
+		return
+	}
+
+	startLoading(func(ctx context.Context) string {
+		return navigatePage(ctx, currentUrl, false, true)
+	})
 }
 
 func onForwardButtonPressed() {
@@ -92,86 +167,545 @@ func onForwardButtonPressed() {
 		return
 	}
 
-	setLoading()
-	go func() {
-		currentUrl, _ := history.GetCurrentUrl()
-		newUrl := navigatePage(currentUrl, false)
-		searchText = newUrl
-		setNavigationButtons()
-		g.Update()
-	}()
+	currentUrl, _ := history.GetCurrentUrl()
+	startLoading(func(ctx context.Context) string {
+		return navigatePage(ctx, currentUrl, false, false)
+	})
+}
+
+func onRetry(event gemini.RetryEvent) {
+	reason := fmt.Sprintf("[%d] %s", int(event.Status), event.Status)
+	if event.Err != nil {
+		reason = event.Err.Error()
+	}
+	content = fmt.Sprintf("Loading... %s, retrying in %s (attempt %d of %d)",
+		reason, event.Delay.Round(100*time.Millisecond), event.Attempt, client.MaxRetries)
+	g.Update()
+}
+
+func onFollowRedirect() {
+	targetUrl := pendingRedirect
+	startLoading(func(ctx context.Context) string {
+		return navigatePage(ctx, targetUrl, true, false)
+	})
+}
+
+func onTrustCertificate() {
+	err := client.KnownHosts.Trust(untrustedHost, untrustedCertificate)
+	if err != nil {
+		content = err.Error()
+		return
+	}
+
+	currentUrl, _ := history.GetCurrentUrl()
+	startLoading(func(ctx context.Context) string {
+		return navigatePage(ctx, currentUrl, false, false)
+	})
+}
+
+func onCreateIdentity() {
+	identity, err := gemini.NewIdentity(identityName, gemini.KEY_ALGORITHM_ECDSA)
+	if err != nil {
+		content = err.Error()
+		return
+	}
+	err = client.Identities.Add(identity)
+	if err != nil {
+		content = err.Error()
+		return
+	}
+	useIdentity(identity.Name)
+}
+
+func onUseIdentity() {
+	identities := client.Identities.List()
+	if int(identityIndex) >= len(identities) {
+		return
+	}
+	useIdentity(identities[identityIndex].Name)
+}
+
+// useIdentity binds an identity to the current page and reloads it.
+func useIdentity(name string) {
+	currentUrl, err := history.GetCurrentUrl()
+	if err != nil {
+		return
+	}
+	err = client.Identities.Bind(name, identityScope(currentUrl))
+	if err != nil {
+		content = err.Error()
+		return
+	}
+	identityName = ""
+
+	startLoading(func(ctx context.Context) string {
+		return navigatePage(ctx, currentUrl, false, false)
+	})
+}
+
+func onEditButtonPressed() {
+	isEditMode = true
+	editText = pageSource
+	editError = ""
+}
+
+// onExportButtonPressed saves the current page as an HTML document in the
+// downloads directory, with links made absolute so they work offline.
+func onExportButtonPressed() {
+	currentUrl, err := history.GetCurrentUrl()
+	if err != nil {
+		return
+	}
+	name := "page"
+	if u, err := url.Parse(currentUrl); err == nil && len(strings.Trim(u.Path, "/")) > 0 {
+		name = strings.TrimSuffix(path.Base(u.Path), path.Ext(u.Path))
+	}
+	file, err := createDownload(name + ".html")
+	if err != nil {
+		exportMessage = err.Error()
+		return
+	}
+	defer file.Close()
+	page := gemtext.ConvertToHtml(pageSource, gemtext.HtmlOptions{BaseUrl: currentUrl, FullDocument: true})
+	if _, err := file.WriteString(page); err != nil {
+		exportMessage = err.Error()
+		return
+	}
+	exportMessage = fmt.Sprintf("Exported to %s", file.Name())
+}
+
+func onCancelEdit() {
+	isEditMode = false
+}
+
+func onUploadEdit() {
+	currentUrl, err := history.GetCurrentUrl()
+	if err != nil {
+		return
+	}
+
+	source := editText
+	startLoading(func(ctx context.Context) string {
+		return uploadPage(ctx, currentUrl, source)
+	})
+}
+
+// uploadPage uploads new gemtext for a page with Titan and shows the updated page.
+// On failure the editor stays open with the error.
+func uploadPage(ctx context.Context, rawurl string, source string) string {
+	resp, err := titan.UploadContext(ctx, &client, rawurl, titan.DEFAULT_MIME, editToken, strings.NewReader(source))
+	if err != nil {
+		editError = loadingErrorMessage(err)
+		var statusErr *gemini.StatusError
+		if errors.As(err, &statusErr) {
+			editError = statusPage(statusErr)
+		}
+		showGemtext(pageSource)
+		isEditMode = true
+		return rawurl
+	}
+	resp.Body.Close()
+
+	if resp.Header.Status.IsRedirect() {
+		if targetUrl, err := gemini.NextUrl(rawurl, resp.Header.Meta); err == nil {
+			return navigatePage(ctx, targetUrl, true, true)
+		}
+	}
+	return navigatePage(ctx, rawurl, false, true)
+}
+
+// identityScope gets the url prefix an identity is bound to for a page.
+func identityScope(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return rawurl
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
 }
 
 func onSubmitInput() {
 	u, _ := url.Parse(searchText)
-	u.RawQuery = inputText
+	u.RawQuery = gemini.EscapeQuery(inputText)
 
-	setLoading()
-	go func() {
-		newUrl := navigatePage(u.String(), true)
-		searchText = newUrl
+	startLoading(func(ctx context.Context) string {
+		newUrl := navigatePage(ctx, u.String(), true, false)
 		inputText = ""
-		setNavigationButtons()
-		g.Update()
-	}()
+		return newUrl
+	})
 }
 
-func navigatePage(rawurl string, shouldPushHistory bool) string {
+// navigatePage fetches a url with the protocol registry. Gemtext and plain
+// text are shown, input prompts are asked for and other media types are
+// saved to the downloads directory.
+func navigatePage(ctx context.Context, rawurl string, shouldPushHistory bool, bypassCache bool) string {
 	isInputMode = false
-	clientResp, err := client.NavigatePage(rawurl)
+	isEditMode = false
+	isCached = false
+	pageSource = ""
+	exportMessage = ""
+	isIdentityMode = false
+	untrustedHost = ""
+	pendingRedirect = ""
+	activeIdentity = ""
+	promptUrls = make(map[string]bool)
+	fetchCtx := ctx
+	if bypassCache {
+		fetchCtx = protocol.WithBypassCache(ctx)
+	}
 
-	if err != nil {
-		content = err.Error()
+	resp, err := protocols.Fetch(fetchCtx, rawurl)
+	var inputErr *protocol.InputError
+	var redirectErr *protocol.RedirectError
+	if errors.As(err, &inputErr) {
 		if shouldPushHistory {
+			history.Push(inputErr.Url)
+		}
+		content = inputErr.Prompt
+		isInputMode = true
+		return inputErr.Url
+	}
+	if errors.As(err, &redirectErr) {
+		if shouldPushHistory {
+			history.Push(redirectErr.Url)
+		}
+		pendingRedirect = redirectErr.Target
+		content = fmt.Sprintf("%s redirects to %s", redirectErr.Url, pendingRedirect)
+		return redirectErr.Url
+	}
+	if err != nil {
+		content = loadingErrorMessage(err)
+		setUntrustedCertificate(err)
+		var statusErr *gemini.StatusError
+		if errors.As(err, &statusErr) {
+			rawurl = statusErr.Url
+			content = statusPage(statusErr)
+			if statusErr.Status.IsClientCertificate() {
+				isIdentityMode = true
+			}
+			if identity, ok := client.Identities.Lookup(rawurl); ok {
+				activeIdentity = identity.Name
+			}
+		}
+		if shouldPushHistory && ctx.Err() == nil {
 			history.Push(rawurl)
 		}
 		return rawurl
 	}
 
-	if clientResp.Response.Header.Status == gemini.STATUS_SUCCESS {
-		if _, ok := clientResp.MimeTypes["text/gemini"]; ok {
-			content = gemtext.ConvertToMarkdown(clientResp.Response.Body)
-		} else {
-			content = fmt.Sprintf("cannot display MIME type: %s", clientResp.Response.Header.Meta)
+	defer resp.Body.Close()
+	if shouldPushHistory {
+		history.Push(resp.Url)
+	}
+	searchText = resp.Url
+	if resp.Gemini != nil {
+		isCached = resp.Gemini.Cached
+		if resp.Gemini.Identity != nil {
+			activeIdentity = resp.Gemini.Identity.Name
 		}
-	} else if clientResp.Response.Header.Status == gemini.STATUS_INPUT {
-		isInputMode = true
-	} else {
-		content = fmt.Sprintf("[%d] %s", clientResp.Response.Header.Status, clientResp.Response.Header.Meta)
 	}
 
-	if shouldPushHistory {
-		history.Push(clientResp.Url)
+	switch resp.MediaType.String() {
+	case "text/gemini":
+		streamGemtext(resp.Body)
+		for _, promptUrl := range gemtext.PromptUrls(pageSource) {
+			promptUrls[promptUrl] = true
+		}
+		if resp.Gemini == nil {
+			// Only gemini pages can be edited with Titan
+			pageSource = ""
+		}
+	case "text/plain":
+		data, err := io.ReadAll(resp.Body)
+		showGemtext(gemtext.Preformat(string(data)))
+		if err != nil {
+			content = loadingErrorMessage(err)
+		}
+	default:
+		name := "download"
+		if u, err := url.Parse(resp.Url); err == nil {
+			name = path.Base(u.Path)
+		}
+		saveDownload(name, resp.Body)
+	}
+	return resp.Url
+}
+
+// saveDownload saves a body to the downloads directory, showing the bytes received.
+func saveDownload(name string, body io.Reader) {
+	file, err := createDownload(name)
+	if err != nil {
+		content = err.Error()
+		return
+	}
+	defer file.Close()
+
+	received := 0
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buffer)
+		if n > 0 {
+			if _, writeErr := file.Write(buffer[:n]); writeErr != nil {
+				content = writeErr.Error()
+				return
+			}
+		}
+		received += n
+		content = fmt.Sprintf("Downloading to %s\n\n%d bytes received", file.Name(), received)
+		g.Update()
+		if err != nil {
+			if err != io.EOF {
+				content += fmt.Sprintf("\n\n%s", loadingErrorMessage(err))
+				return
+			}
+			content = fmt.Sprintf("Saved %d bytes to %s", received, file.Name())
+			return
+		}
+	}
+}
+
+// createDownload creates a new file in the downloads directory without
+// replacing existing files.
+func createDownload(name string) (*os.File, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(homeDir, "Downloads")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if name == "." || name == "/" || len(name) == 0 {
+		name = "download"
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 0; ; i++ {
+		filename := name
+		if i > 0 {
+			filename = fmt.Sprintf("%s (%d)%s", base, i, ext)
+		}
+		file, err := os.OpenFile(filepath.Join(dir, filename), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if !errors.Is(err, os.ErrExist) || i >= 100 {
+			return file, err
+		}
 	}
+}
+
+// statusPage explains a failure status.
+func statusPage(statusErr *gemini.StatusError) string {
+	page := fmt.Sprintf("[%d] %s\n\n%s", int(statusErr.Status), statusErr.Status, statusErr.Status.Description())
+	if len(statusErr.Meta) > 0 {
+		page += fmt.Sprintf("\n\nThe server said: %s", statusErr.Meta)
+	}
+	return page
+}
+
+// streamGemtext renders a gemtext body as chunks arrive.
+func streamGemtext(body io.Reader) {
+	var text strings.Builder
+	buffer := make([]byte, 4096)
+	for {
+		n, err := body.Read(buffer)
+		if n > 0 {
+			text.Write(buffer[:n])
+			pageSource = text.String()
+			showGemtext(pageSource)
+			g.Update()
+		}
+		if err != nil {
+			if err != io.EOF {
+				content = loadingErrorMessage(err)
+			}
+			return
+		}
+	}
+}
 
-	return clientResp.Url
+// showGemtext shows gemtext as the page, clearing any message.
+func showGemtext(text string) {
+	contentBlocks = gemtext.RenderImgui(gemtext.ParseString(text))
+	content = ""
+}
+
+// loadingErrorMessage describes an error that stopped a page load.
+func loadingErrorMessage(err error) string {
+	if errors.Is(err, context.Canceled) {
+		return "Stopped loading"
+	}
+	return err.Error()
+}
+
+// setUntrustedCertificate records a certificate rejected by TOFU so the user can trust it.
+func setUntrustedCertificate(err error) {
+	var unknownErr *gemini.UnknownHostError
+	var changedErr *gemini.CertificateChangedError
+	var expiredErr *gemini.ExpiredPinError
+	if errors.As(err, &unknownErr) {
+		untrustedHost = unknownErr.Host
+		untrustedCertificate = unknownErr.Certificate
+	} else if errors.As(err, &changedErr) {
+		untrustedHost = changedErr.Host
+		untrustedCertificate = changedErr.Certificate
+		content += "\n\nThis may mean someone is intercepting your connection."
+	} else if errors.As(err, &expiredErr) {
+		untrustedHost = expiredErr.Host
+		untrustedCertificate = expiredErr.Certificate
+	}
 }
 
 func setNavigationButtons() {
 	isBackButtonDisabled = !history.CanGoBack()
 	isForwardButtonDisabled = !history.CanGoForward()
 	isSearchButtonDisabled = false
+	isLoading = false
 }
 
+// setLoading shows the loading state.
 func setLoading() {
 	content = "Loading..."
+	contentBlocks = nil
 	isBackButtonDisabled = true
 	isForwardButtonDisabled = true
 	isSearchButtonDisabled = true
+	isLoading = true
+}
+
+// startLoading cancels any load in progress, then runs load in the background
+// with a context cancelled by the Stop button. The new load waits for the
+// cancelled one to finish, so a stale page never replaces it.
+func startLoading(load func(ctx context.Context) string) {
+	if stopLoading != nil {
+		stopLoading()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopLoading = cancel
+	previous := loadingDone
+	done := make(chan struct{})
+	loadingDone = done
+	setLoading()
+
+	go func() {
+		defer close(done)
+		if previous != nil {
+			<-previous
+			setLoading()
+		}
+		newUrl := load(ctx)
+		searchText = newUrl
+		setNavigationButtons()
+		g.Update()
+	}()
+}
+
+func onStopButtonPressed() {
+	if stopLoading != nil {
+		stopLoading()
+	}
+}
+
+// pageWidget shows the page blocks followed by the message in content.
+func pageWidget() g.Widget {
+	onLink := func(url string) {
+		go onContentMetaClicked(url)
+	}
+	onBlockLink := func(url string) {
+		go onContentMetaClicked(gemtext.ImguiLinkUrl(url))
+	}
+	widgets := make([]g.Widget, 0, len(contentBlocks)+1)
+	for i := range contentBlocks {
+		block := &contentBlocks[i]
+		switch block.Kind {
+		case gemtext.IMGUI_BLOCK_MARKDOWN:
+			widgets = append(widgets, g.Markdown(&block.Text).OnLink(onBlockLink))
+		case gemtext.IMGUI_BLOCK_QUOTE:
+			widgets = append(widgets, g.Row(
+				g.Dummy(16, 0),
+				g.Style().SetColor(g.StyleColorText, quoteColor).To(g.Label(block.Text).Wrapped(true)),
+			))
+		case gemtext.IMGUI_BLOCK_PREFORMATTED:
+			// Preformatted text is never wrapped so ASCII art keeps its shape
+			widgets = append(widgets, g.Label(block.Text).Font(monospaceFont))
+		default:
+			widgets = append(widgets, g.Label(block.Text).Wrapped(true))
+		}
+	}
+	if len(content) > 0 {
+		widgets = append(widgets, g.Markdown(&content).OnLink(onLink))
+	}
+	return g.Column(widgets...)
 }
 
 func Loop() {
 	var contentWidget g.Widget
-	if isInputMode {
+	if isEditMode {
+		contentWidget = g.Column(
+			g.InputTextMultiline(&editText).Size(g.Auto, 400),
+			g.Row(
+				g.InputText(&editToken).Hint("Token"),
+				g.Button("Upload").OnClick(onUploadEdit).Disabled(isLoading),
+				g.Button("Cancel").OnClick(onCancelEdit),
+			),
+			g.Label(editError).Wrapped(true),
+		)
+	} else if isInputMode {
 		contentWidget = g.Row(
 			g.InputText(&inputText),
 			g.Event().OnKeyPressed(g.KeyEnter, onSubmitInput),
 			g.Button("Submit").OnClick(onSubmitInput),
 		)
+	} else if isIdentityMode && client.Identities != nil {
+		identities := client.Identities.List()
+		names := make([]string, len(identities))
+		for i, identity := range identities {
+			names[i] = identity.Name
+		}
+		preview := ""
+		if int(identityIndex) < len(names) {
+			preview = names[identityIndex]
+		}
+		contentWidget = g.Column(
+			g.Label(content).Wrapped(true),
+			g.Row(
+				g.InputText(&identityName).Hint("Identity name"),
+				g.Button("Create identity").OnClick(onCreateIdentity),
+			),
+			g.Row(
+				g.Combo("##identity", preview, names, &identityIndex),
+				g.Button("Use existing identity").OnClick(onUseIdentity).Disabled(len(names) == 0),
+			),
+		)
+	} else if len(pendingRedirect) > 0 {
+		contentWidget = g.Column(
+			g.Label(content).Wrapped(true),
+			g.Button("Follow redirect").OnClick(onFollowRedirect),
+		)
+	} else if len(untrustedHost) > 0 {
+		contentWidget = g.Column(
+			g.Label(content).Wrapped(true),
+			g.Button("Trust certificate").OnClick(onTrustCertificate),
+		)
 	} else {
-		contentWidget = g.Markdown(&content).OnLink(func(url string) {
-			go onContentMetaClicked(url)
-		})
+		contentWidget = pageWidget()
+	}
+
+	var stopButton g.Widget = g.Dummy(0, 0)
+	if isLoading {
+		stopButton = g.Button("Stop").OnClick(onStopButtonPressed)
+	}
+
+	identityLabel := "No identity"
+	if len(activeIdentity) > 0 {
+		identityLabel = fmt.Sprintf("Identity: %s", activeIdentity)
+	}
+	var cachedLabel g.Widget = g.Dummy(0, 0)
+	if isCached {
+		cachedLabel = g.Label("(cached)")
+	}
+	var exportLabel g.Widget = g.Dummy(0, 0)
+	if len(exportMessage) > 0 {
+		exportLabel = g.Label(exportMessage)
 	}
 
 	g.SingleWindow().Layout(
@@ -180,9 +714,16 @@ func Loop() {
 				g.Row(
 					g.Button("<").OnClick(onBackButtonPressed).Disabled(isBackButtonDisabled),
 					g.Button(">").OnClick(onForwardButtonPressed).Disabled(isForwardButtonDisabled),
+					g.Button("Reload").OnClick(onReloadButtonPressed).Disabled(isSearchButtonDisabled),
 					g.InputText(&searchText),
 					g.Event().OnKeyPressed(g.KeyEnter, onSubmitSearch),
 					g.Button("Go").OnClick(onSubmitSearch).Disabled(isSearchButtonDisabled),
+					stopButton,
+					g.Button("Edit this page").OnClick(onEditButtonPressed).Disabled(len(pageSource) == 0 || isLoading),
+					g.Button("Export HTML").OnClick(onExportButtonPressed).Disabled(len(pageSource) == 0 || isLoading),
+					g.Label(identityLabel),
+					cachedLabel,
+					exportLabel,
 				),
 			),
 			g.TableRow(